@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/go-humanize/english"
+	"github.com/xyproto/mode"
+)
+
+const (
+	maxArchiveEntries = 100000          // archives with more entries are only partially read
+	archiveTimeout    = 2 * time.Second // the maximum time spent reading each archive
+)
+
+// errNotArchive is returned by ReadArchive for compressed files that do not contain a tar archive
+var errNotArchive = errors.New("not an archive")
+
+// ArchiveInfo is a summary of the contents of an archive
+type ArchiveInfo struct {
+	Entries          int    `json:"entries"`
+	UncompressedSize int64  `json:"uncompressed_size"`
+	TopLevelDir      string `json:"top_level_dir"` // the directory that contains all entries, or ""
+	Tarbomb          bool   `json:"tarbomb"`       // true if several entries would be unpacked into the current directory
+	Complete         bool   `json:"complete"`      // false if the entry or time limit was reached
+	Compression      string `json:"compression"`   // like "Gzip", or "" if not compressed
+}
+
+// ReadArchive calls visit for each entry in the given archive, until visit
+// returns false, maxArchiveEntries is reached or archiveTimeout has passed.
+// The format is the format from the signature database. Returns true if all
+// entries were visited.
+func ReadArchive(filename, format string, visit func(name string, info fs.FileInfo) bool) (bool, error) {
+	deadline := time.Now().Add(archiveTimeout)
+	switch format {
+	case "ZIP", "JAR", "APK":
+		r, err := zip.OpenReader(filename)
+		if err != nil {
+			return false, err
+		}
+		defer r.Close()
+		for i, f := range r.File {
+			if i >= maxArchiveEntries || time.Now().After(deadline) || !visit(f.Name, f.FileInfo()) {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "Tar", "Gzip", "Bzip2", "XZ", "Zstandard":
+		f, err := os.Open(filename)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		var r io.Reader = f
+		switch format {
+		case "Gzip":
+			gr, err := gzip.NewReader(f)
+			if err != nil {
+				return false, err
+			}
+			defer gr.Close()
+			r = gr
+		case "Bzip2":
+			r = bzip2.NewReader(f)
+		case "XZ":
+			if r, err = newXZReader(f); err != nil {
+				return false, err
+			}
+		case "Zstandard":
+			if r, err = newZstdReader(f); err != nil {
+				return false, err
+			}
+		}
+		tr := tar.NewReader(r)
+		for i := 0; ; i++ {
+			header, err := tr.Next()
+			if err == io.EOF {
+				if i == 0 && format != "Tar" {
+					return false, errNotArchive
+				}
+				return true, nil
+			} else if err != nil {
+				if i == 0 && format != "Tar" {
+					return false, errNotArchive // compressed data, but not a tar archive
+				}
+				return false, err
+			}
+			if i >= maxArchiveEntries || time.Now().After(deadline) || !visit(header.Name, header.FileInfo()) {
+				return false, nil
+			}
+		}
+	}
+	return false, errNotArchive
+}
+
+// InspectArchive summarizes the contents of the given archive
+func InspectArchive(filename, format string) (*ArchiveInfo, error) {
+	info := &ArchiveInfo{}
+	switch format {
+	case "Gzip", "Bzip2", "XZ", "Zstandard":
+		info.Compression = format
+	}
+	var (
+		topLevel     = make(map[string]bool) // the first path element of each entry
+		topLevelFile bool                    // true if there are files directly in the top level
+	)
+	complete, err := ReadArchive(filename, format, func(name string, fileInfo fs.FileInfo) bool {
+		info.Entries++
+		if !fileInfo.IsDir() {
+			info.UncompressedSize += fileInfo.Size()
+		}
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+		if first, rest, _ := strings.Cut(name, "/"); first != "" {
+			topLevel[first] = true
+			if rest == "" && !fileInfo.IsDir() {
+				topLevelFile = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	info.Complete = complete
+	if len(topLevel) == 1 && !topLevelFile {
+		for name := range topLevel {
+			info.TopLevelDir = name
+		}
+	}
+	// JAR files and Android packages are not meant to be unpacked
+	if format != "JAR" && format != "APK" {
+		info.Tarbomb = len(topLevel) > 1
+	}
+	return info, nil
+}
+
+// Summary returns a short description, like "12 entries, 3.4 MiB, in project-1.0/"
+func (info *ArchiveInfo) Summary() string {
+	entries := fmt.Sprintf("%d %s", info.Entries, english.PluralWord(info.Entries, "entry", "entries"))
+	if !info.Complete {
+		entries = fmt.Sprintf("%d+ entries", info.Entries)
+	}
+	fields := []string{entries, humanize.IBytes(uint64(info.UncompressedSize))}
+	if info.TopLevelDir != "" {
+		fields = append(fields, "in "+info.TopLevelDir+"/")
+	} else if info.Tarbomb {
+		fields = append(fields, "tarbomb")
+	}
+	return strings.Join(fields, ", ")
+}
+
+// archiveEntryType returns a FileTypeInfo for an archive entry, based on the name only
+func archiveEntryType(name string, fileInfo fs.FileInfo) FileTypeInfo {
+	var (
+		m        mode.Mode = mode.Blank
+		mimeType           = "inode/directory"
+	)
+	if !fileInfo.IsDir() {
+		m = mode.Detect(name)
+		mimeType, _ = mimeTypes().LookupGlob(name)
+	}
+	description, typeColor, nameColor := getTypeDescriptionAndColors(m, false, fileInfo.IsDir())
+	return FileTypeInfo{
+		Mode:        m,
+		MIME:        mimeType,
+		Description: description,
+		TypeColor:   typeColor,
+		NameColor:   nameColor,
+		LineCount:   -1,
+	}
+}
+
+// ListArchives lists the contents of each archive, in the same format as the file listing
+func (cfg *Config) ListArchives(ob *strings.Builder, findings *Findings, needsSeparator *bool) {
+	for _, archiveRow := range findings.rows {
+		if archiveRow.Type.Archive == nil {
+			continue
+		}
+		var rows []FileRow
+		complete, err := ReadArchive(filepath.Join(cfg.path, archiveRow.Path), archiveRow.Type.Format, func(name string, fileInfo fs.FileInfo) bool {
+			rows = append(rows, FileRow{Path: name, Info: fileInfo, Type: archiveEntryType(name, fileInfo)})
+			return true
+		})
+		if err != nil {
+			continue
+		}
+		if *needsSeparator {
+			ob.WriteString("\n")
+			*needsSeparator = false
+		}
+		ob.WriteString(fmt.Sprintf("<%s>%s</%s>:\n", archiveRow.Type.NameColor, archiveRow.Path, archiveRow.Type.NameColor))
+		SortRows(rows, "name", false)
+		table := Table{
+			Rows:         make([][]string, len(rows)),
+			RightAlign:   []bool{false, false, false, true}, // right-align the size column
+			MaxWidth:     cfg.termWidth,
+			ShrinkColumn: 0, // ellipsize the file names, if needed
+			Colors:       cfg.colors,
+		}
+		for i := range rows {
+			table.Rows[i] = rows[i].Cells()
+		}
+		ob.WriteString(table.Render())
+		if !complete {
+			ob.WriteString("<yellow>Only the first entries are listed.</yellow>\n")
+		}
+		*needsSeparator = true
+	}
+}
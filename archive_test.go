@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+// The files in testdata are a tar archive with a LICENSE and a README.md in
+// hello-1.0/, compressed with "xz -6" and "zstd -19"
+var compressedArchives = []struct {
+	filename  string
+	format    string
+	newReader func(io.Reader) (io.Reader, error)
+}{
+	{"testdata/hello.tar.xz", "XZ", func(r io.Reader) (io.Reader, error) { return newXZReader(r) }},
+	{"testdata/hello.tar.zst", "Zstandard", func(r io.Reader) (io.Reader, error) { return newZstdReader(r) }},
+}
+
+func TestInspectCompressedArchive(t *testing.T) {
+	for _, tc := range compressedArchives {
+		t.Run(tc.format, func(t *testing.T) {
+			info, err := InspectArchive(tc.filename, tc.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Compression != tc.format || info.Entries != 3 || info.TopLevelDir != "hello-1.0" || !info.Complete {
+				t.Errorf("got %+v", info)
+			}
+		})
+	}
+}
+
+// TestDecompressors checks that the XZ and Zstandard decoders give the same tar archive
+func TestDecompressors(t *testing.T) {
+	var outputs [][]byte
+	for _, tc := range compressedArchives {
+		data, err := os.ReadFile(tc.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := tc.newReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.filename, err)
+		}
+		outputs = append(outputs, output)
+	}
+	if len(outputs[0]) != 10240 || !bytes.Equal(outputs[0], outputs[1]) {
+		t.Errorf("got %d and %d bytes that differ", len(outputs[0]), len(outputs[1]))
+	}
+}
+
+// TestDecompressorsCorrupt checks that the decoders do not panic on truncated
+// or damaged data, and that truncated data does not give partial output
+// without an error. The checksums are not verified, so damaged data may be
+// decoded without an error.
+func TestDecompressorsCorrupt(t *testing.T) {
+	decode := func(newReader func(io.Reader) (io.Reader, error), data []byte) ([]byte, error) {
+		r, err := newReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+	for _, tc := range compressedArchives {
+		t.Run(tc.format, func(t *testing.T) {
+			data, err := os.ReadFile(tc.filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := decode(tc.newReader, data)
+			for n := range len(data) {
+				if got, err := decode(tc.newReader, data[:n]); err == nil && !bytes.Equal(got, want) {
+					t.Errorf("truncated at %d bytes: got %d bytes and no error", n, len(got))
+				}
+				damaged := bytes.Clone(data)
+				damaged[n] ^= 0x55
+				_, _ = decode(tc.newReader, damaged)
+			}
+		})
+	}
+}
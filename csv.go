@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvColumns are the columns that can be selected with --columns
+var csvColumns = []string{"path", "type", "size", "lines", "mtime", "binary", "git"}
+
+// defaultCSVColumns are the columns that are written by --csv and --tsv by default
+const defaultCSVColumns = "path,type,size,lines,mtime,binary"
+
+// csvCell returns the value of the given column for a file row
+func csvCell(row *FileRow, column string, gitStatus func(string) string) string {
+	switch column {
+	case "path":
+		return row.Path
+	case "type":
+		return row.Type.Description
+	case "size":
+		return strconv.FormatInt(row.Size(), 10)
+	case "lines":
+		if row.Type.LineCount < 0 {
+			return ""
+		}
+		return strconv.Itoa(row.Type.LineCount)
+	case "mtime":
+		return row.Modified().Format(time.RFC3339)
+	case "binary":
+		return strconv.FormatBool(row.Type.IsBinary)
+	case "git":
+		return gitStatus(row.Path)
+	}
+	return ""
+}
+
+// WriteCSV writes the file rows as comma or tab separated values, with a header line
+func (cfg *Config) WriteCSV(w io.Writer, findings *Findings, comma rune) error {
+	gitStatus := func(string) string { return "" }
+	for _, column := range cfg.columns {
+		if column == "git" {
+			gitStatus = GitStatus(cfg.path)
+			break
+		}
+	}
+
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Comma = comma
+	if err := csvWriter.Write(cfg.columns); err != nil {
+		return err
+	}
+	SortRows(findings.rows, cfg.sortKey, cfg.reverse)
+	record := make([]string, len(cfg.columns))
+	for i := range findings.rows {
+		for j, column := range cfg.columns {
+			record[j] = csvCell(&findings.rows[i], column, gitStatus)
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// maxInterpreterSize is the maximum size of the interpreter path that is read from PT_INTERP
+const maxInterpreterSize = 4096
+
+// ELFInfo contains information about an ELF executable, shared library or object file
+type ELFInfo struct {
+	Arch        string   `json:"arch"`
+	Bits        int      `json:"bits"`
+	Kind        string   `json:"kind"` // "executable", "PIE", "shared library", "object" or "core dump"
+	Dynamic     bool     `json:"dynamic"`
+	Interpreter string   `json:"interpreter,omitempty"`
+	Stripped    bool     `json:"stripped"`
+	Needed      []string `json:"needed"` // the shared libraries that are needed
+}
+
+// elfArch is a short name for an ELF machine type, and the word size that
+// the name implies, or 0 if the name is used for both 32-bit and 64-bit
+type elfArch struct {
+	name string
+	bits int
+}
+
+// elfArchNames are short names for the most common ELF machine types
+var elfArchNames = map[elf.Machine]elfArch{
+	elf.EM_X86_64:    {"x86-64", 64},
+	elf.EM_386:       {"x86", 32},
+	elf.EM_AARCH64:   {"ARM64", 64},
+	elf.EM_ARM:       {"ARM", 32},
+	elf.EM_RISCV:     {"RISC-V", 0},
+	elf.EM_PPC64:     {"PowerPC64", 64},
+	elf.EM_PPC:       {"PowerPC", 0},
+	elf.EM_MIPS:      {"MIPS", 0},
+	elf.EM_S390:      {"s390x", 64},
+	elf.EM_LOONGARCH: {"LoongArch", 0},
+	elf.EM_SPARCV9:   {"SPARC64", 64},
+}
+
+// elfArchName returns the name of the given machine type, with the word size
+// if the name does not imply it, like "RISC-V 64-bit"
+func elfArchName(machine elf.Machine, bits int) string {
+	arch, ok := elfArchNames[machine]
+	if !ok {
+		return fmt.Sprintf("%s %d-bit", strings.TrimPrefix(machine.String(), "EM_"), bits)
+	}
+	if arch.bits != bits { // also for 32-bit programs for x86-64, which use the x32 ABI
+		return fmt.Sprintf("%s %d-bit", arch.name, bits)
+	}
+	return arch.name
+}
+
+// InspectELF reads the headers of the given ELF file
+func InspectELF(filename string) (*ELFInfo, error) {
+	f, err := elf.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &ELFInfo{Bits: 32}
+	if f.Class == elf.ELFCLASS64 {
+		info.Bits = 64
+	}
+	info.Arch = elfArchName(f.Machine, info.Bits)
+
+	for _, prog := range f.Progs {
+		switch prog.Type {
+		case elf.PT_INTERP:
+			// The size comes from the file, so only read as much as a path could be
+			data, err := io.ReadAll(io.LimitReader(prog.Open(), maxInterpreterSize))
+			if err == nil { // success
+				info.Interpreter, _, _ = strings.Cut(string(data), "\x00")
+			}
+		case elf.PT_DYNAMIC:
+			info.Dynamic = true
+		}
+	}
+
+	switch f.Type {
+	case elf.ET_EXEC:
+		info.Kind = "executable"
+	case elf.ET_DYN:
+		// Position independent executables are shared objects with an interpreter or the PIE flag
+		info.Kind = "shared library"
+		if info.Interpreter != "" {
+			info.Kind = "PIE"
+		} else if flags, err := f.DynValue(elf.DT_FLAGS_1); err == nil && len(flags) > 0 && flags[0]&uint64(elf.DF_1_PIE) != 0 {
+			info.Kind = "PIE"
+		}
+	case elf.ET_REL:
+		info.Kind = "object"
+	case elf.ET_CORE:
+		info.Kind = "core dump"
+	default:
+		info.Kind = strings.TrimPrefix(f.Type.String(), "ET_")
+	}
+
+	info.Stripped = f.Section(".symtab") == nil
+
+	if info.Dynamic {
+		if needed, err := f.ImportedLibraries(); err == nil { // success
+			info.Needed = needed
+		}
+	}
+
+	return info, nil
+}
+
+// Summary returns a short description, like "ELF x86-64 PIE, dynamic, stripped"
+func (info *ELFInfo) Summary() string {
+	fields := []string{fmt.Sprintf("ELF %s %s", info.Arch, info.Kind)}
+	if info.Kind != "object" && info.Kind != "core dump" {
+		if info.Dynamic {
+			fields = append(fields, "dynamic")
+		} else {
+			fields = append(fields, "static")
+		}
+	}
+	if info.Stripped {
+		fields = append(fields, "stripped")
+	}
+	return strings.Join(fields, ", ")
+}
+
+// ELFLibraries lists the shared libraries that are needed by each ELF file, in verbose mode
+func (cfg *Config) ELFLibraries(ob *strings.Builder, findings *Findings, needsSeparator *bool) {
+	var rows []*FileRow
+	for i := range findings.rows {
+		if elfInfo := findings.rows[i].Type.ELF; elfInfo != nil && len(elfInfo.Needed) > 0 {
+			rows = append(rows, &findings.rows[i])
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	if *needsSeparator {
+		ob.WriteString("\n")
+		*needsSeparator = false
+	}
+	slices.SortFunc(rows, func(a, b *FileRow) int { return strings.Compare(a.Path, b.Path) })
+	for _, row := range rows {
+		ob.WriteString(fmt.Sprintf("<lightred>%s</lightred> <gray>needs</gray> <white>%s</white>", row.Path, strings.Join(row.Type.ELF.Needed, ", ")))
+		if row.Type.ELF.Interpreter != "" {
+			ob.WriteString(fmt.Sprintf(" <gray>(interpreter %s)</gray>", row.Type.ELF.Interpreter))
+		}
+		ob.WriteString("\n")
+	}
+	*needsSeparator = true
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"debug/elf"
+	"testing"
+)
+
+func TestELFArchName(t *testing.T) {
+	for _, tc := range []struct {
+		machine elf.Machine
+		bits    int
+		want    string
+	}{
+		{elf.EM_X86_64, 64, "x86-64"},
+		{elf.EM_X86_64, 32, "x86-64 32-bit"},
+		{elf.EM_386, 32, "x86"},
+		{elf.EM_AARCH64, 64, "ARM64"},
+		{elf.EM_RISCV, 64, "RISC-V 64-bit"},
+		{elf.EM_RISCV, 32, "RISC-V 32-bit"},
+		{elf.EM_MIPS, 32, "MIPS 32-bit"},
+		{elf.EM_MIPS, 64, "MIPS 64-bit"},
+		{elf.EM_PPC, 32, "PowerPC 32-bit"},
+		{elf.EM_PPC64, 64, "PowerPC64"},
+		{elf.EM_AVR, 32, "AVR 32-bit"},
+	} {
+		if got := elfArchName(tc.machine, tc.bits); got != tc.want {
+			t.Errorf("%s, %d-bit: got %q, want %q", tc.machine, tc.bits, got, tc.want)
+		}
+	}
+}
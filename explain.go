@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xyproto/mode"
+)
+
+// Explanation records the steps of the file type detection, for --explain
+type Explanation struct {
+	steps [][2]string // the name of each step, and what was found
+}
+
+// Add records a step of the file type detection. Does nothing if the explanation is nil.
+func (explanation *Explanation) Add(step, format string, args ...any) {
+	if explanation == nil {
+		return
+	}
+	explanation.steps = append(explanation.steps, [2]string{step, fmt.Sprintf(format, args...)})
+}
+
+// modeName returns the name of the given mode, or "no mode" for mode.Blank
+func modeName(m mode.Mode) string {
+	if m == mode.Blank {
+		return "no mode"
+	}
+	return m.String()
+}
+
+// quoteStart quotes the first 40 bytes of the given data, and adds "..." if there are more
+func quoteStart(data []byte) string {
+	if len(data) > 40 {
+		return fmt.Sprintf("%q...", data[:40])
+	}
+	return fmt.Sprintf("%q", data)
+}
+
+// Explain writes each step of the file type detection for the given path,
+// and the resulting description and colors
+func (cfg *Config) Explain(ob *strings.Builder, filename string) error {
+	fInfo, err := os.Lstat(filename)
+	if err != nil {
+		return err
+	}
+	explanation := &Explanation{}
+	typeInfo := cfg.detectFileType(filename, fInfo, explanation)
+
+	ob.WriteString(fmt.Sprintf("<white>%s</white>:\n", filename))
+	table := Table{
+		Rows:   make([][]string, len(explanation.steps)),
+		Colors: cfg.colors,
+	}
+	for i, step := range explanation.steps {
+		table.Rows[i] = []string{"<gray>" + step[0] + "</gray>", step[1]}
+	}
+	ob.WriteString(table.Render())
+
+	row := FileRow{Path: filepath.Base(filename), Info: fInfo, Type: typeInfo}
+	cells := row.Cells()
+	ob.WriteString(fmt.Sprintf("\n<gray>result:</gray> %s %s <gray>(mode: %s, MIME type: %s)</gray>\n", cells[0], cells[1], modeName(typeInfo.Mode), typeInfo.MIME))
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file or directory on the system, regardless of path
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// getFileID returns the device and inode numbers for the given os.FileInfo.
+// Returns false if they are not available.
+func getFileID(fileInfo os.FileInfo) (fileID, bool) {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{uint64(stat.Dev), uint64(stat.Ino)}, true
+}
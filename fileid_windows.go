@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// fileID identifies a file or directory on the system, regardless of path
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// getFileID is not implemented on Windows, where symlinks are rare
+func getFileID(fileInfo os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
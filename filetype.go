@@ -6,28 +6,42 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/dustin/go-humanize/english"
 	"github.com/xyproto/binary"
-	"github.com/xyproto/mime"
 	"github.com/xyproto/mode"
 )
 
 const maxBinaryDetectionFileSize = 1024 * 1024 * 1024
 
-var mi *mime.Reader
-
 // FileTypeInfo contains comprehensive information about a file's type
 type FileTypeInfo struct {
 	Mode        mode.Mode
 	IsBinary    bool
+	Format      string // the binary format, like "PNG", if found in the signature database
+	MIME        string // the MIME type, like "image/png"
 	Description string
 	TypeColor   string
 	NameColor   string
-	LineCount   int // -1 if not counted
+	LineCount   int            // -1 if not counted
+	Breakdown   *LineBreakdown // nil if not counted, or if the comment syntax is unknown
+	Link        *LinkInfo      // nil if this is not a symbolic link
+	ELF         *ELFInfo       // nil if this is not an ELF file
+	GoBuild     *GoBuildInfo   // nil if this is not a Go binary
+	Archive     *ArchiveInfo   // nil if this is not an archive, or if it could not be read
+	Image       *ImageInfo     // nil if this is not a PNG, JPEG or GIF image
+	Media       *MediaInfo     // nil if this is not a supported video or audio file, or if it could not be read
+	Text        *TextFormat    // nil for binary and empty files, and if the contents were not read
 }
 
 // DetectFileType performs comprehensive file type detection similar to Orbiton
 // If data is nil, detection will be based only on the filename
 func DetectFileType(filename string, fileInfo os.FileInfo, data []byte) FileTypeInfo {
+	return detectFileType(filename, fileInfo, data, nil)
+}
+
+// detectFileType is DetectFileType, where each step of the detection is
+// recorded in the given explanation, unless it is nil
+func detectFileType(filename string, fileInfo os.FileInfo, data []byte, explanation *Explanation) FileTypeInfo {
 	var (
 		m           mode.Mode
 		isBinary    bool
@@ -35,34 +49,93 @@ func DetectFileType(filename string, fileInfo os.FileInfo, data []byte) FileType
 		typeColor   string
 		nameColor   string
 		lineCount   = -1
+		breakdown   *LineBreakdown
+		signature   *Signature
+		textFormat  *TextFormat
 	)
 
 	// Check if it's a directory first
 	if fileInfo.IsDir() {
+		explanation.Add("directory", "the path is a directory")
 		return FileTypeInfo{
 			Mode:        mode.Blank,
 			Description: "Directory",
+			MIME:        "inode/directory",
 			TypeColor:   "magenta",
 			NameColor:   "lightcyan",
 			LineCount:   -1,
 		}
 	}
 
+	// Symbolic links are described by what they point to
+	if IsSymlink(fileInfo) {
+		explanation.Add("symlink", "the path is a symbolic link, which is described by its target")
+		return detectSymlink(filename)
+	}
+
+	// User-defined type rules come before the built-in detection
+	rule := matchTypeRule(filename, data)
+	if rule != nil {
+		explanation.Add("rule", "matched the user-defined rule %s", rule)
+	} else {
+		explanation.Add("rule", "no user-defined rule matched (%d %s)", len(typeRules), english.PluralWord(len(typeRules), "rule", "rules"))
+	}
+
 	// Initial mode detection based on filename/extension
 	m = mode.Detect(filename)
+	explanation.Add("filename", "mode.Detect(%q) returned %s", filepath.Base(filename), modeName(m))
+	if rule != nil && rule.mode != mode.Blank {
+		m = rule.mode
+		explanation.Add("filename", "the mode is %s, from the rule", modeName(m))
+	}
+
+	if data == nil {
+		explanation.Add("contents", "the contents were not read, so only the filename is used")
+	}
 
 	// If we have file contents, do deeper analysis
 	if data != nil {
 		// Check if file is binary
 		isBinary = binary.Data(data)
+		explanation.Add("binary", "binary.Data returned %t, for a sample of the %d bytes that were read", isBinary, len(data))
+
+		// Check for known binary formats, unless there is a user-defined rule for this file
+		if rule == nil {
+			if signature = DetectSignature(filename, data, isBinary); signature != nil {
+				m = mode.Blank
+				isBinary = true
+				explanation.Add("signature", "the first bytes match the %s signature, so the file is binary", signature.Format)
+			} else {
+				explanation.Add("signature", "no binary format signature matched")
+			}
+		}
+
+		// UTF-16 text is converted to UTF-8, so that it can be analyzed like other text
+		encoding := DetectEncoding(data)
+		if signature == nil && strings.HasPrefix(encoding, "UTF-16") {
+			if decoded := decodeUTF16(data, encoding); isMostlyPrintable(decoded) {
+				data = decoded
+				isBinary = false
+				explanation.Add("encoding", "%s text, which is converted to UTF-8 and treated as text", encoding)
+			} else {
+				// The first bytes only looked like a byte order mark. Bytes like 0xff and 0xfe are not valid UTF-8.
+				encoding = "Latin-1"
+				explanation.Add("encoding", "the first bytes look like UTF-16, but the decoded text is not printable")
+			}
+		} else if !isBinary {
+			explanation.Add("encoding", "%s", encoding)
+		}
 
 		if !isBinary {
 			// Count lines for text files
 			lineCount = bytes.Count(data, []byte{'\n'})
 
+			// The mode from a user-defined rule is used as it is
+			hasRuleMode := rule != nil && rule.mode != mode.Blank
+
 			// If mode is blank or certain special cases, try content detection
-			if m == mode.Blank || m == mode.Prolog || m == mode.Config ||
-				(m == mode.Markdown && !strings.HasSuffix(strings.ToLower(filename), ".md")) {
+			if !hasRuleMode && (m == mode.Blank || m == mode.Prolog || m == mode.Config ||
+				(m == mode.Markdown && !strings.HasSuffix(strings.ToLower(filename), ".md"))) {
 
 				// Look at first line/chunk for content detection
 				var firstChunk []byte
@@ -79,50 +152,164 @@ func DetectFileType(filename string, fileInfo os.FileInfo, data []byte) FileType
 
 				// Try to detect from content
 				if newMode, found := mode.DetectFromContentBytes(m, firstChunk, func() []byte { return data }); found {
+					explanation.Add("content", "mode.DetectFromContentBytes found %s, in the first line %s", modeName(newMode), quoteStart(firstChunk))
 					m = newMode
+				} else {
+					explanation.Add("content", "mode.DetectFromContentBytes found nothing, in the first line %s", quoteStart(firstChunk))
 				}
+			} else if !hasRuleMode {
+				explanation.Add("content", "the contents are not used for the mode, since %s is not Blank, Prolog, Configuration or Markdown without .md", modeName(m))
 			}
 
 			// Special case for Assembly files
-			if m == mode.Assembly {
+			if m == mode.Assembly && !hasRuleMode {
 				if newMode, found := mode.DetectFromContentBytes(m, data, func() []byte { return data }); found {
+					explanation.Add("content", "mode.DetectFromContentBytes found %s, in all of the contents, since the mode was Assembly", modeName(newMode))
 					m = newMode
 				}
 			}
 
+			// Count code, comment and blank lines for source code
+			if syntax, ok := commentSyntaxFor(m); ok {
+				lines := CountLines(data, syntax)
+				breakdown = &lines
+			}
+
+			// Find the encoding, line endings and indentation
+			if len(data) > 0 {
+				textFormat = AnalyzeText(data, encoding, m)
+			}
 		}
 	}
 
-	if m == mode.Blank && fileInfo.Size() < maxBinaryDetectionFileSize {
+	if data == nil && m == mode.Blank && fileInfo.Mode().IsRegular() && fileInfo.Size() > 0 && fileInfo.Size() < maxBinaryDetectionFileSize {
 		if data, err := os.ReadFile(filename); err == nil { // success
 			isBinary = binary.Data(data)
+			explanation.Add("binary", "the mode is unknown, so the file was read: binary.Data returned %t", isBinary)
 		}
 	}
 
 	// Determine colors and description based on the detected type
 	description, typeColor, nameColor = getTypeDescriptionAndColors(m, isBinary, fileInfo.IsDir())
+	explanation.Add("type", "getTypeDescriptionAndColors returned %q, type color %s and name color %s (mode: %s, binary: %t)", description, typeColor, nameColor, modeName(m), isBinary)
+	if rule != nil {
+		if rule.Description != "" {
+			description = rule.Description
+		}
+		if rule.TypeColor != "" {
+			typeColor = rule.TypeColor
+		}
+		if rule.NameColor != "" {
+			nameColor = rule.NameColor
+		}
+		explanation.Add("type", "%q, type color %s and name color %s, from the rule", description, typeColor, nameColor)
+	}
 
-	// Keep the colors but change the description if the file is empty
-	if fileInfo.Size() == 0 {
-		description = "Empty"
+	// Use the name of the binary format, if it is known
+	var (
+		format  string
+		elfInfo *ELFInfo
+		goBuild *GoBuildInfo
+		archive *ArchiveInfo
+		img     *ImageInfo
+		media   *MediaInfo
+	)
+	if signature != nil {
+		description = signature.Description
+		format = signature.Format
+		explanation.Add("format", "%q, from the %s signature", description, format)
 	}
 
-	if description == "Unknown" {
-		if mi == nil {
-			mi = mime.New("testconf/mime.types", true)
+	// Inspect the contents of known binary formats
+	switch format {
+	case "ELF":
+		if info, err := InspectELF(filename); err == nil { // success
+			elfInfo = info
+			description = info.Summary()
 		}
-		mimeDescription := strings.TrimSpace(mi.Get(filepath.Ext(filename)))
-		if mimeDescription != "" {
-			description = mimeDescription
-			if strings.Contains(description, "/") {
-				fields := strings.SplitN(description, "/", 2)
-				description = strings.TrimPrefix(fields[1], "x-")
-				firstLetter := strings.ToUpper(string(description[0]))
-				description = firstLetter + description[1:]
+	}
+
+	// Read the dimensions of images
+	switch format {
+	case "PNG", "JPEG", "GIF":
+		if info, err := InspectImage(data); err == nil { // success
+			img = info
+			description = info.Summary()
+		}
+	}
+
+	// Read the duration and streams of videos and audio files
+	switch format {
+	case "MP4", "QuickTime", "M4A", "Matroska", "WebM", "Ogg", "FLAC", "MP3", "WAV", "AVI":
+		if info, err := InspectMedia(filename, format); err == nil { // success
+			media = info
+			description = info.Summary()
+		}
+	}
+
+	// Peek into archives
+	switch format {
+	case "ZIP", "JAR", "APK", "Tar", "Gzip", "Bzip2", "XZ", "Zstandard":
+		if info, err := InspectArchive(filename, format); err == nil { // success
+			archive = info
+			if info.Compression != "" {
+				description = info.Compression + " compressed tar archive"
 			}
+			description += ", " + info.Summary()
+		}
+	}
+
+	// Go binaries have embedded build information
+	switch format {
+	case "ELF", "PE", "Mach-O":
+		if info, err := InspectGoBuild(filename); err == nil { // success
+			goBuild = info
+			description += ", " + info.GoVersion
+		}
+	}
+	if format != "" {
+		explanation.Add("format", "%q, after inspecting the contents", description)
+	}
+
+	// Find the MIME type by the filename, or else by the contents.
+	// Some extensions, like ".bin", only tell that the file is binary.
+	mimeType, mimeSource := mimeTypes().LookupGlob(filename)
+	fromMagic := false
+	if (mimeType == "" || mimeType == "application/octet-stream") && data != nil {
+		if magicMIMEType := mimeTypes().LookupMagic(data); magicMIMEType != "" {
+			mimeType, mimeSource = magicMIMEType, "the shared-mime-info magic rules"
+			fromMagic = true
 		}
 	}
 
+	// Describe files that are not recognized in any other way by their MIME type.
+	// Binary files are only described by a MIME type that was found by the contents,
+	// since the extension of a binary file, like ".txt", may not agree with the contents.
+	if (description == "Unknown" || (description == "Binary" && fromMagic)) && mimeType != "" && rule == nil {
+		description = mimeDescription(mimeType)
+		explanation.Add("mime", "%s, from %s, which gives the description %q", mimeType, mimeSource, description)
+	} else if mimeType != "" {
+		explanation.Add("mime", "%s, from %s", mimeType, mimeSource)
+	}
+
+	if mimeType == "" {
+		switch {
+		case fileInfo.Size() == 0:
+			mimeType = "application/x-zerosize"
+		case isBinary:
+			mimeType = "application/octet-stream"
+		default:
+			mimeType = "text/plain"
+		}
+		explanation.Add("mime", "%s, since the MIME type was not found", mimeType)
+	}
+
+	// Keep the colors but change the description if the file is empty
+	if fileInfo.Size() == 0 {
+		description = "Empty"
+		explanation.Add("empty", "the file is empty, so the description is \"Empty\"")
+	}
+
 	return FileTypeInfo{
 		Mode:        m,
 		IsBinary:    isBinary,
@@ -130,7 +317,46 @@ func DetectFileType(filename string, fileInfo os.FileInfo, data []byte) FileType
 		TypeColor:   typeColor,
 		NameColor:   nameColor,
 		LineCount:   lineCount,
+		Breakdown:   breakdown,
+		Format:      format,
+		MIME:        mimeType,
+		ELF:         elfInfo,
+		GoBuild:     goBuild,
+		Archive:     archive,
+		Image:       img,
+		Media:       media,
+		Text:        textFormat,
+	}
+}
+
+// detectSymlink returns a FileTypeInfo for the symbolic link at the given path
+func detectSymlink(filename string) FileTypeInfo {
+	typeInfo := FileTypeInfo{
+		Mode:        mode.Blank,
+		Description: "Symlink",
+		MIME:        "inode/symlink",
+		TypeColor:   "blue",
+		NameColor:   "lightblue",
+		LineCount:   -1,
+	}
+	linkInfo, err := ReadLink(filename)
+	if err != nil {
+		typeInfo.Description = "Unreadable symlink"
+		typeInfo.TypeColor, typeInfo.NameColor = "red", "lightred"
+		return typeInfo
+	}
+	typeInfo.Link = linkInfo
+	switch {
+	case linkInfo.Loop:
+		typeInfo.Description = "Symlink loop"
+		typeInfo.TypeColor, typeInfo.NameColor = "red", "lightred"
+	case linkInfo.Broken:
+		typeInfo.Description = "Broken symlink"
+		typeInfo.TypeColor, typeInfo.NameColor = "red", "lightred"
+	case linkInfo.TargetInfo.IsDir():
+		typeInfo.Description = "Directory symlink"
 	}
+	return typeInfo
 }
 
 // getTypeDescriptionAndColors returns appropriate colors and description for the file type
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
-	"time"
+	"sync/atomic"
+	"syscall"
 
-	"github.com/iafan/cwalk"
 	"github.com/xyproto/files"
 )
 
@@ -17,10 +20,43 @@ type Findings struct {
 	mut          sync.Mutex
 	regularFiles []string
 	ignoredFiles []string
+	walkErrors   []WalkError
+	truncated    bool          // the walk stopped early, because of ExamineOptions.MaxEntries
+	interrupted  bool          // the walk was cancelled
+	ndjson       *NDJSONWriter // if set, entries are written out instead of being stored
 	infoMap      map[string]os.FileInfo
 	dirList      []string
 	fileList     []string
-	printMap     map[time.Time]string
+	rows         []FileRow
+}
+
+// Entry is a single file or directory found by ExamineStream
+type Entry struct {
+	Path    string // relative to the examined directory
+	Info    os.FileInfo
+	Ignored bool  // ignored, hidden or in a vendor or .git directory
+	Err     error // set if the entry could not be read
+}
+
+// WalkError is an entry that could not be read while walking
+type WalkError struct {
+	Path string
+	Kind string // a short description of the error, like "permission denied"
+	Err  error
+}
+
+// errorKind returns a short description of the given error
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return "permission denied"
+	case errors.Is(err, fs.ErrNotExist):
+		return "does not exist"
+	case errors.Is(err, syscall.ELOOP):
+		return "symlink loop"
+	default:
+		return "unreadable"
+	}
 }
 
 func NewFindings() *Findings {
@@ -31,166 +67,171 @@ func NewFindings() *Findings {
 	return &findings
 }
 
+// Add stores the given entry as either a regular file, an ignored file or an error
+func (findings *Findings) Add(entry Entry) {
+	findings.mut.Lock()
+	defer findings.mut.Unlock()
+	if entry.Err != nil {
+		walkError := WalkError{entry.Path, errorKind(entry.Err), entry.Err}
+		if findings.ndjson != nil {
+			findings.ndjson.WriteError(walkError)
+			return
+		}
+		findings.walkErrors = append(findings.walkErrors, walkError)
+		return
+	}
+	if findings.ndjson != nil {
+		if entry.Ignored {
+			findings.ndjson.WriteIgnored(entry.Path, entry.Info)
+		}
+		return // regular files are written when they have been analyzed
+	}
+	if entry.Ignored {
+		findings.ignoredFiles = append(findings.ignoredFiles, entry.Path)
+	} else {
+		findings.regularFiles = append(findings.regularFiles, entry.Path)
+	}
+	findings.infoMap[entry.Path] = entry.Info
+}
+
+// FindGit looks for a .git directory in the given path and stores the Git info, if found
+func (findings *Findings) FindGit(path string) {
+	if git, err := NewGit(filepath.Join(path, ".git")); err == nil { // success
+		findings.git = git
+	}
+}
+
 func SplitPath(path string) []string {
 	return strings.Split(path, string(filepath.Separator))
 }
 
-func Examine(path string, respectIgnoreFiles, respectHiddenFiles bool, maxDepth int) (*Findings, error) {
+// ErrTruncated is returned by ExamineStream when ExamineOptions.MaxEntries is reached
+var ErrTruncated = errors.New("the maximum number of entries was reached")
+
+// ExamineOptions configures how Examine and ExamineStream walk a directory.
+// The entries directly in the examined directory have depth 1.
+type ExamineOptions struct {
+	RespectIgnoreFiles bool
+	RespectHiddenFiles bool
+	FollowSymlinks     bool
+	MinDepth           int // entries with a lower depth are walked, but not listed
+	MaxDepth           int // -1 for unlimited
+	MaxEntries         int // stop the walk after this many regular entries, 0 for unlimited
+}
+
+// Examine walks the given path and collects all entries in a Findings struct
+func Examine(path string, opts ExamineOptions) (*Findings, error) {
+	findings := NewFindings()
+	entries := make(chan Entry)
+	walkErr := make(chan error, 1)
+	go func() {
+		walkErr <- ExamineStream(context.Background(), path, opts, entries)
+	}()
+	for entry := range entries {
+		findings.Add(entry)
+	}
+	if err := <-walkErr; errors.Is(err, ErrTruncated) {
+		findings.truncated = true
+	} else if err != nil {
+		return nil, err
+	}
+	findings.FindGit(path)
+	return findings, nil
+}
+
+// ExamineStream walks the given path and sends each entry to the entries
+// channel as soon as it is found. The channel is closed when the walk is done.
+// If ctx is cancelled, the walk stops and ctx.Err() is returned. If the
+// maximum number of entries is reached, the walk stops and ErrTruncated is
+// returned.
+func ExamineStream(ctx context.Context, path string, opts ExamineOptions, entries chan<- Entry) error {
+	defer close(entries)
+
 	if !files.IsDir(path) {
-		return nil, fmt.Errorf("not a path: %s", path)
+		return fmt.Errorf("not a path: %s", path)
 	}
 
-	findings := NewFindings()
+	var ignorer *Ignorer
+	if opts.RespectIgnoreFiles {
+		ignorer = NewIgnorer(path)
+	}
 
-	var ignoreMut sync.Mutex
-	var extraIgnoredFiles []string
-	var wg sync.WaitGroup
+	// The walk is cancelled when the maximum number of entries is reached
+	walkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		entryCount atomic.Int64
+		truncated  atomic.Bool
+	)
+
+	sendEntry := func(entry Entry) error {
+		select {
+		case entries <- entry:
+			return nil
+		case <-walkCtx.Done():
+			return filepath.SkipDir // the walk returns ctx.Err()
+		}
+	}
 
-	walkFunc := func(path string, fileInfo os.FileInfo, err error) error {
+	send := func(relPath string, fileInfo os.FileInfo, ignored bool) error {
+		return sendEntry(Entry{Path: relPath, Info: fileInfo, Ignored: ignored})
+	}
+
+	walkFunc := func(relPath string, fileInfo os.FileInfo, err error) error {
 		if err != nil {
-			return fmt.Errorf("os.Lstat error: %v", err)
+			// Report the error and continue the walk
+			if relPath == "" {
+				relPath = "."
+			}
+			sendEntry(Entry{Path: relPath, Info: fileInfo, Err: err})
+			return filepath.SkipDir
 		}
-		if path == "" {
+		if relPath == "" {
 			return nil // skip
 		}
-		parts := SplitPath(path)
-		if len(parts) == 0 {
-			return fmt.Errorf("no path given: %s", path)
-		} else if len(parts) > maxDepth {
+		parts := SplitPath(relPath)
+		depth := len(parts)
+		if depth == 0 {
+			return fmt.Errorf("no path given: %s", relPath)
+		} else if opts.MaxDepth >= 0 && depth > opts.MaxDepth {
 			return filepath.SkipDir // skip this directory
 		}
-		head := strings.ToLower(parts[0])
-		if head == "vendor" {
-			if respectIgnoreFiles {
-				// Store the ignored file
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					findings.mut.Lock()
-					findings.ignoredFiles = append(findings.ignoredFiles, path)
-					findings.infoMap[path] = fileInfo
-					findings.mut.Unlock()
-				}()
-			} else {
-				// Store a regular file
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					findings.mut.Lock()
-					findings.regularFiles = append(findings.regularFiles, path)
-					findings.infoMap[path] = fileInfo
-					findings.mut.Unlock()
-				}()
+		listed := depth >= opts.MinDepth
+		isDir := fileInfo.IsDir()
+		name := strings.ToLower(parts[depth-1])
+		ignored := (opts.RespectIgnoreFiles && (name == "vendor" || name == ".git")) || // vendor and .git directories
+			(opts.RespectHiddenFiles && len(name) > 1 && strings.HasPrefix(name, ".")) || // hidden files and directories
+			(opts.RespectIgnoreFiles && ignorer.Ignored(relPath, isDir)) // .gitignore and .ignore patterns
+		if ignored {
+			if listed {
+				send(relPath, fileInfo, true)
 			}
-			return nil // skip
+			return filepath.SkipDir // don't walk ignored directories
 		}
-		if head == ".git" {
-			if respectIgnoreFiles {
-				// Store the ignored file
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					findings.mut.Lock()
-					findings.ignoredFiles = append(findings.ignoredFiles, path)
-					findings.infoMap[path] = fileInfo
-					findings.mut.Unlock()
-				}()
-			} else {
-				// Store a regular file
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					findings.mut.Lock()
-					findings.regularFiles = append(findings.regularFiles, path)
-					findings.infoMap[path] = fileInfo
-					findings.mut.Unlock()
-				}()
-			}
-			// Find and store Git info
-			foundGit := false
-			findings.mut.Lock()
-			foundGit = findings.git != nil
-			findings.mut.Unlock()
-			if !foundGit {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					git, err := NewGit(head) // pass in the path to the .git directory
-					if err != nil {
-						return // don't store the git struct in the findings
-					}
-					findings.mut.Lock()
-					findings.git = git
-					findings.mut.Unlock()
-				}()
+		if listed {
+			if opts.MaxEntries > 0 && entryCount.Add(1) > int64(opts.MaxEntries) {
+				truncated.Store(true)
+				cancel()
+				return filepath.SkipDir
 			}
-			return nil // skip
-		}
-		if respectHiddenFiles && len(head) > 1 && strings.HasPrefix(head, ".") {
-			// Store the ignored file
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				findings.mut.Lock()
-				findings.ignoredFiles = append(findings.ignoredFiles, path)
-				findings.infoMap[path] = fileInfo
-				findings.mut.Unlock()
-			}()
-			return nil // skip
-		}
-		if respectIgnoreFiles && (head == ".ignore" || head == ".gitignore") {
-			if extraIgnoredFilesData, err := os.ReadFile(head); err == nil { // success
-				lines := strings.Split(string(extraIgnoredFilesData), "\n")
-				for _, line := range lines {
-					trimmedLine := strings.TrimSpace(line)
-					if trimmedLine != "" && !strings.HasPrefix(trimmedLine, "#") {
-						ignoreMut.Lock()
-						extraIgnoredFiles = append(extraIgnoredFiles, trimmedLine)
-						ignoreMut.Unlock()
-					}
-				}
+			if err := send(relPath, fileInfo, false); err != nil {
+				return err
 			}
 		}
-		if respectHiddenFiles && len(head) > 1 && strings.HasPrefix(head, ".") {
-			ignoreMut.Lock()
-			extraIgnoredFiles = append(extraIgnoredFiles, head)
-			ignoreMut.Unlock()
+		if opts.MaxDepth >= 0 && depth >= opts.MaxDepth {
+			return filepath.SkipDir // don't walk deeper than MaxDepth
 		}
-		// Store a regular file
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			findings.mut.Lock()
-			findings.regularFiles = append(findings.regularFiles, path)
-			findings.infoMap[path] = fileInfo
-			findings.mut.Unlock()
-		}()
 		return nil // all good
 	}
 
-	if err := cwalk.Walk(path, walkFunc); err != nil {
-		return nil, err
+	var err error
+	if opts.FollowSymlinks {
+		err = WalkWithSymlinks(walkCtx, path, walkFunc)
+	} else {
+		err = Walk(walkCtx, path, walkFunc)
 	}
-
-	hasS := func(xs []string, x string) (bool, int) {
-		for i, e := range xs {
-			if x == e {
-				return true, i
-			}
-		}
-		return false, -1
+	if truncated.Load() && ctx.Err() == nil {
+		return ErrTruncated
 	}
-
-	wg.Wait() // The mutexes above are not needed after this point
-
-	for _, extraIgnoredFile := range extraIgnoredFiles {
-		if ok, index := hasS(findings.regularFiles, extraIgnoredFile); ok {
-			// delete extraIgnoredFile from findings.regular by appending two sliced string slices
-			findings.regularFiles = append(findings.regularFiles[:index], findings.regularFiles[index+1:]...)
-			// add extraIgnoredFile to findings.ignoredFiles
-			findings.ignoredFiles = append(findings.ignoredFiles, extraIgnoredFile)
-		}
-	}
-
-	return findings, nil
+	return err
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,9 @@ import (
 	"time"
 
 	"github.com/dustin/go-humanize"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/xyproto/files"
 )
 
@@ -57,6 +61,110 @@ func NewGit(path string) (*Git, error) {
 	return &git, nil
 }
 
+// LatestCommit returns the latest commit in the git repository at the given
+// path, made after the given time. Returns nil if there is no such commit.
+func LatestCommit(path string, since time.Time) (*object.Commit, error) {
+	r, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	cIter, err := r.Log(&git.LogOptions{From: ref.Hash(), Since: &since, Until: &now})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *object.Commit
+	// ignore err here because we want to break the loop early
+	_ = cIter.ForEach(func(c *object.Commit) error {
+		latest = c
+		return errors.New("break")
+	})
+	return latest, nil
+}
+
+// IsOlderCommit checks if the given revision is a commit in the git
+// repository at the given path that HEAD was built on, but is not HEAD itself
+func IsOlderCommit(path, revision string) (bool, error) {
+	r, err := git.PlainOpen(path)
+	if err != nil {
+		return false, err
+	}
+	ref, err := r.Head()
+	if err != nil {
+		return false, err
+	}
+	if ref.Hash().String() == revision {
+		return false, nil
+	}
+	c, err := r.CommitObject(plumbing.NewHash(revision))
+	if err != nil {
+		return false, err // not a commit in this repository
+	}
+	head, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return false, err
+	}
+	return c.IsAncestor(head)
+}
+
+// gitStatusNames are the descriptions of the git status codes
+var gitStatusNames = map[git.StatusCode]string{
+	git.Unmodified:         "unmodified",
+	git.Untracked:          "untracked",
+	git.Modified:           "modified",
+	git.Added:              "added",
+	git.Deleted:            "deleted",
+	git.Renamed:            "renamed",
+	git.Copied:             "copied",
+	git.UpdatedButUnmerged: "unmerged",
+}
+
+// GitStatus returns a function that describes the git status of a file, like
+// "modified" or "untracked". The file paths are relative to the given path,
+// which may be anywhere inside of the git repository. The function returns
+// an empty string for files that are not in a git repository.
+func GitStatus(path string) func(string) string {
+	notInRepository := func(string) string { return "" }
+	r, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return notInRepository
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return notInRepository
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return notInRepository
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return notInRepository
+	}
+	prefix, err := filepath.Rel(wt.Filesystem.Root(), absPath)
+	if err != nil {
+		return notInRepository
+	}
+	return func(filename string) string {
+		fileStatus, ok := status[filepath.ToSlash(filepath.Join(prefix, filename))]
+		if !ok {
+			return gitStatusNames[git.Unmodified]
+		}
+		// Changes in the worktree are more recent than changes in the staging area
+		if fileStatus.Worktree != git.Unmodified {
+			return gitStatusNames[fileStatus.Worktree]
+		}
+		return gitStatusNames[fileStatus.Staging]
+	}
+}
+
 // GitHighlightLines applies syntax highlighting for a git log line
 func GitHighlightLines(lines []string) string {
 	var sb strings.Builder
@@ -4,17 +4,17 @@ go 1.23.2
 
 require (
 	github.com/dustin/go-humanize v1.0.1
+	github.com/go-git/go-billy/v5 v5.6.2
 	github.com/go-git/go-git/v5 v5.14.0
-	github.com/iafan/cwalk v0.0.0-20210125030640-586a8832a711
 	github.com/spf13/cobra v1.9.1
 	github.com/xyproto/binary v1.3.3
 	github.com/xyproto/distrodetector v1.3.1
 	github.com/xyproto/files v1.9.0
-	github.com/xyproto/mime v0.3.0
 	github.com/xyproto/mode v0.11.1
 	github.com/xyproto/ollamaclient/v2 v2.7.1
 	github.com/xyproto/textoutput v1.17.1
 	github.com/xyproto/usermodel v1.2.2
+	github.com/xyproto/vt100 v1.16.11
 )
 
 require (
@@ -26,7 +26,6 @@ require (
 	github.com/cyphar/filepath-securejoin v0.4.1 // indirect
 	github.com/emirpasic/gods v1.18.1 // indirect
 	github.com/go-git/gcfg v1.5.1-0.20230307220236-3a3c6141e376 // indirect
-	github.com/go-git/go-billy/v5 v5.6.2 // indirect
 	github.com/golang/groupcache v0.0.0-20241129210726-2c02b8208cf8 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/jbenet/go-context v0.0.0-20150711004518-d14ea06fba99 // indirect
@@ -44,7 +43,6 @@ require (
 	github.com/xyproto/burnfont v1.2.3 // indirect
 	github.com/xyproto/env/v2 v2.5.3 // indirect
 	github.com/xyproto/lookslikegoasm v1.0.0 // indirect
-	github.com/xyproto/vt100 v1.16.11 // indirect
 	golang.org/x/crypto v0.36.0 // indirect
 	golang.org/x/net v0.37.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
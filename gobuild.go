@@ -0,0 +1,102 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// GoBuildInfo contains the build information that is embedded in Go binaries
+type GoBuildInfo struct {
+	GoVersion string `json:"go_version"`     // like "go1.23.2"
+	Path      string `json:"path"`           // the path of the main package
+	Module    string `json:"module"`         // the path of the main module
+	Version   string `json:"module_version"` // like "(devel)" or "v1.2.3"
+	Revision  string `json:"revision"`       // the VCS revision, if embedded
+	Modified  bool   `json:"modified"`       // true if built from a tree with uncommitted changes
+	BuildTime string `json:"build_time"`     // the time of the revision, if embedded
+	Stale     bool   `json:"stale"`          // true if built from an older commit than HEAD
+}
+
+// InspectGoBuild reads the build information from the given Go binary.
+// Returns an error if the file is not a Go binary.
+func InspectGoBuild(filename string) (*GoBuildInfo, error) {
+	bi, err := buildinfo.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	info := &GoBuildInfo{
+		GoVersion: bi.GoVersion,
+		Path:      bi.Path,
+		Module:    bi.Main.Path,
+		Version:   bi.Main.Version,
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		case "vcs.time":
+			info.BuildTime = setting.Value
+		}
+	}
+	return info, nil
+}
+
+// shortRevision returns the first 7 characters of a revision
+func shortRevision(revision string) string {
+	if len(revision) > 7 {
+		return revision[:7]
+	}
+	return revision
+}
+
+// Summary returns a short description of the build information, like
+// "go1.23.2, github.com/xyproto/listfiles, revision 1a2b3c4 (modified)"
+func (info *GoBuildInfo) Summary() string {
+	fields := []string{info.GoVersion}
+	if info.Module != "" {
+		fields = append(fields, info.Module)
+	} else if info.Path != "" {
+		fields = append(fields, info.Path)
+	}
+	if info.Revision != "" {
+		revision := "revision " + shortRevision(info.Revision)
+		if info.Modified {
+			revision += " (modified)"
+		}
+		fields = append(fields, revision)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// GoBinaries lists the Go binaries that were built from an older commit than
+// HEAD, and the build information of all Go binaries in verbose mode
+func (cfg *Config) GoBinaries(ob *strings.Builder, findings *Findings, needsSeparator *bool) {
+	var rows []*FileRow
+	for i := range findings.rows {
+		if goBuild := findings.rows[i].Type.GoBuild; goBuild != nil && (goBuild.Stale || cfg.verbose) {
+			rows = append(rows, &findings.rows[i])
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	if *needsSeparator {
+		ob.WriteString("\n")
+		*needsSeparator = false
+	}
+	slices.SortFunc(rows, func(a, b *FileRow) int { return strings.Compare(a.Path, b.Path) })
+	for _, row := range rows {
+		goBuild := row.Type.GoBuild
+		if cfg.verbose {
+			ob.WriteString(fmt.Sprintf("<lightred>%s</lightred> <gray>was built with</gray> <white>%s</white>\n", row.Path, goBuild.Summary()))
+		}
+		if goBuild.Stale {
+			ob.WriteString(fmt.Sprintf("<lightred>%s</lightred>: <yellow>binary is stale (built from an older commit, %s)</yellow>\n", row.Path, shortRevision(goBuild.Revision)))
+		}
+	}
+	*needsSeparator = true
+}
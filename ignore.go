@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// ignoreFilenames are the per-directory ignore files, in increasing order of priority
+var ignoreFilenames = []string{".gitignore", ".ignore"}
+
+// Ignorer decides if paths below a root directory are ignored, using the same
+// rules as git: patterns from core.excludesFile and .git/info/exclude, followed
+// by the .gitignore (and .ignore) files of every directory from the top of the
+// repository and down to the directory of the path that is checked.
+type Ignorer struct {
+	root     string
+	domain   []string            // the root directory, relative to the top of the git repository
+	patterns []gitignore.Pattern // patterns that apply to everything below root
+	mut      sync.Mutex
+	dirCache map[string][]gitignore.Pattern // patterns from the ignore files in each directory below root
+}
+
+// findGitTop searches from the given directory and upwards for a directory
+// that contains .git. Returns the path and true if found.
+func findGitTop(dir string) (string, bool) {
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil { // success
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// readIgnoreFile parses the given ignore file. The domain is the directory
+// that contains the ignore file, relative to the top of the repository.
+// Returns nil if the file could not be read.
+func readIgnoreFile(filename string, domain []string) []gitignore.Pattern {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+	return patterns
+}
+
+// globalIgnorePatterns returns the patterns from the core.excludesFile setting
+// in /etc/gitconfig and ~/.gitconfig. If core.excludesFile is not set,
+// $XDG_CONFIG_HOME/git/ignore or ~/.config/git/ignore is used, like git does.
+func globalIgnorePatterns() []gitignore.Pattern {
+	fs := osfs.New("/")
+	patterns, _ := gitignore.LoadSystemPatterns(fs)
+	globalPatterns, _ := gitignore.LoadGlobalPatterns(fs)
+	if globalPatterns == nil {
+		configDir := os.Getenv("XDG_CONFIG_HOME")
+		if configDir == "" {
+			if home, err := os.UserHomeDir(); err == nil { // success
+				configDir = filepath.Join(home, ".config")
+			}
+		}
+		if configDir != "" {
+			globalPatterns = readIgnoreFile(filepath.Join(configDir, "git", "ignore"), nil)
+		}
+	}
+	return append(patterns, globalPatterns...)
+}
+
+// NewIgnorer prepares an Ignorer for the given root directory
+func NewIgnorer(root string) *Ignorer {
+	ig := &Ignorer{
+		root:     root,
+		dirCache: make(map[string][]gitignore.Pattern),
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		absRoot = root
+	}
+	top, found := findGitTop(absRoot)
+	if !found {
+		return ig
+	}
+	ig.patterns = globalIgnorePatterns()
+	ig.patterns = append(ig.patterns, readIgnoreFile(filepath.Join(top, ".git", "info", "exclude"), nil)...)
+	// Collect the patterns from the ignore files in the directories above root
+	if rel, err := filepath.Rel(top, absRoot); err == nil && rel != "." {
+		ig.domain = SplitPath(rel)
+		for i := 0; i < len(ig.domain); i++ {
+			dir := filepath.Join(append([]string{top}, ig.domain[:i]...)...)
+			for _, ignoreFilename := range ignoreFilenames {
+				ig.patterns = append(ig.patterns, readIgnoreFile(filepath.Join(dir, ignoreFilename), ig.domain[:i])...)
+			}
+		}
+	}
+	return ig
+}
+
+// dirPatterns returns the patterns from the ignore files in the given
+// directory, which is relative to root. The result is cached.
+func (ig *Ignorer) dirPatterns(relDir string, domain []string) []gitignore.Pattern {
+	ig.mut.Lock()
+	defer ig.mut.Unlock()
+	if patterns, ok := ig.dirCache[relDir]; ok {
+		return patterns
+	}
+	var patterns []gitignore.Pattern
+	for _, ignoreFilename := range ignoreFilenames {
+		patterns = append(patterns, readIgnoreFile(filepath.Join(ig.root, relDir, ignoreFilename), domain)...)
+	}
+	ig.dirCache[relDir] = patterns
+	return patterns
+}
+
+// Ignored checks if the given path, relative to root, is ignored.
+// The directories above the path are assumed to not be ignored.
+func (ig *Ignorer) Ignored(relPath string, isDir bool) bool {
+	parts := SplitPath(relPath)
+	fullPath := append(append([]string{}, ig.domain...), parts...)
+	patterns := append([]gitignore.Pattern{}, ig.patterns...)
+	for i := 0; i < len(parts); i++ {
+		relDir := filepath.Join(parts[:i]...)
+		patterns = append(patterns, ig.dirPatterns(relDir, fullPath[:len(ig.domain)+i])...)
+	}
+	return gitignore.NewMatcher(patterns).Match(fullPath, isDir)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnorer(t *testing.T) {
+	// Keep the ignore patterns of the user out of the test
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	repo := t.TempDir()
+	for filename, contents := range map[string]string{
+		".git/info/exclude":  "*.secret\n",
+		".gitignore":         "# build output\n*.log\n!keep.log\nbuild/\n/top.txt\ndocs/**/draft.md\n",
+		"sub/.gitignore":     "!debug.log\n*.tmp\n",
+		"sub/deeper/.ignore": "data.txt\n",
+	} {
+		path := filepath.Join(repo, filepath.FromSlash(filename))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		root  string // relative to the top of the repository
+		path  string // relative to root
+		isDir bool
+		want  bool
+	}{
+		// Patterns from the top of the repository
+		{".", "app.log", false, true},
+		{".", "keep.log", false, false},
+		{".", "other/app.log", false, true},
+		{".", "x.secret", false, true},
+
+		// Patterns from a subdirectory are added to the ones from its parents
+		{".", "sub/app.log", false, true},
+		{".", "sub/debug.log", false, false},
+		{".", "other/debug.log", false, true},
+		{".", "sub/x.tmp", false, true},
+		{".", "x.tmp", false, false},
+		{".", "sub/deeper/data.txt", false, true},
+		{".", "sub/data.txt", false, false},
+
+		// Patterns that end with a slash only match directories
+		{".", "build", true, true},
+		{".", "build", false, false},
+		{".", "sub/build", true, true},
+
+		// Patterns that start with a slash only match at the top of the repository
+		{".", "top.txt", false, true},
+		{".", "sub/top.txt", false, false},
+
+		// ** matches any number of directories
+		{".", "docs/draft.md", false, true},
+		{".", "docs/a/b/draft.md", false, true},
+		{".", "docs/a/final.md", false, false},
+		{".", "other/docs/draft.md", false, false},
+
+		// A root below the top of the repository
+		{"sub", "app.log", false, true},
+		{"sub", "debug.log", false, false},
+		{"sub", "x.tmp", false, true},
+		{"sub", "build", true, true},
+		{"sub", "top.txt", false, false},
+		{"sub", "deeper/data.txt", false, true},
+		{"sub/deeper", "data.txt", false, true},
+		{"sub/deeper", "debug.log", false, false},
+		{"docs", "draft.md", false, true},
+		{"docs", "a/draft.md", false, true},
+	} {
+		ig := NewIgnorer(filepath.Join(repo, filepath.FromSlash(tc.root)))
+		if got := ig.Ignored(filepath.FromSlash(tc.path), tc.isDir); got != tc.want {
+			t.Errorf("%s in %s (directory: %t): got %t, want %t", tc.path, tc.root, tc.isDir, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register the decoders that are used by image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+	"time"
+)
+
+// ImageInfo contains the dimensions and color model of an image, and the
+// EXIF capture time and orientation of JPEG images, if present
+type ImageInfo struct {
+	Format      string     `json:"format"`
+	Width       int        `json:"width"`
+	Height      int        `json:"height"`
+	ColorModel  string     `json:"color_model"`
+	Taken       *time.Time `json:"taken,omitempty"`       // the EXIF DateTimeOriginal
+	Orientation int        `json:"orientation,omitempty"` // the EXIF orientation, 1 is normal
+}
+
+// exifOrientations describes the EXIF orientation values, except 1 (normal)
+var exifOrientations = map[int]string{
+	2: "mirrored",
+	3: "rotated 180°",
+	4: "mirrored, rotated 180°",
+	5: "mirrored, rotated 90° CCW",
+	6: "rotated 90° CW",
+	7: "mirrored, rotated 90° CW",
+	8: "rotated 90° CCW",
+}
+
+// pngColorTypes describes the color types in the PNG IHDR chunk
+var pngColorTypes = map[byte]string{
+	0: "grayscale",
+	2: "RGB",
+	3: "paletted",
+	4: "grayscale with alpha",
+	6: "RGBA",
+}
+
+// InspectImage reads the dimensions and color model from the header of an image
+func InspectImage(data []byte) (*ImageInfo, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	info := &ImageInfo{
+		Format:     strings.ToUpper(format),
+		Width:      config.Width,
+		Height:     config.Height,
+		ColorModel: colorModelName(config.ColorModel),
+	}
+	switch format {
+	case "png":
+		// The color model from image.DecodeConfig does not tell RGB and RGBA apart
+		if len(data) >= 26 && string(data[12:16]) == "IHDR" {
+			if name, ok := pngColorTypes[data[25]]; ok {
+				info.ColorModel = name
+			}
+		}
+	case "jpeg":
+		info.Taken, info.Orientation = readEXIF(data)
+	}
+	return info, nil
+}
+
+// colorModelName returns a short name for the given color model
+func colorModelName(model color.Model) string {
+	if _, ok := model.(color.Palette); ok {
+		return "paletted"
+	}
+	switch model {
+	case color.RGBAModel, color.NRGBAModel:
+		return "RGBA"
+	case color.RGBA64Model, color.NRGBA64Model:
+		return "RGBA64"
+	case color.GrayModel, color.Gray16Model:
+		return "grayscale"
+	case color.YCbCrModel:
+		return "YCbCr"
+	case color.CMYKModel:
+		return "CMYK"
+	}
+	return "unknown color model"
+}
+
+// Summary returns a short description, like "PNG 1920x1080, RGBA"
+func (info *ImageInfo) Summary() string {
+	fields := []string{fmt.Sprintf("%s %dx%d", info.Format, info.Width, info.Height), info.ColorModel}
+	if info.Taken != nil {
+		fields = append(fields, "taken "+info.Taken.Format("2006-01-02 15:04"))
+	}
+	if orientation, ok := exifOrientations[info.Orientation]; ok {
+		fields = append(fields, orientation)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// readEXIF finds the EXIF capture time and orientation in the APP1 segment of a JPEG image.
+// Returns nil and 0 if they are not found.
+func readEXIF(data []byte) (taken *time.Time, orientation int) {
+	// Find the APP1 segment, among the segments that come before the image data
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xda || length < 2 || i+2+length > len(data) { // start of scan, or corrupt or truncated
+			break
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFF(segment[6:])
+		}
+		i += 2 + length
+	}
+	return nil, 0
+}
+
+// parseTIFF reads the orientation from IFD0 and the capture time from the EXIF IFD
+func parseTIFF(tiff []byte) (taken *time.Time, orientation int) {
+	if len(tiff) < 8 {
+		return nil, 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0
+	}
+	type entry struct {
+		tag, typ uint16
+		count    uint32
+		value    []byte // the four bytes that contain the value, or the offset of the value
+	}
+	// entries returns the entries in the IFD at the given offset.
+	// The offsets are compared as uint64, since int(offset) can overflow on 32-bit platforms.
+	entries := func(offset uint32) []entry {
+		if uint64(offset)+2 > uint64(len(tiff)) {
+			return nil
+		}
+		n := int(order.Uint16(tiff[offset:]))
+		var result []entry
+		for i := range n {
+			start := int(offset) + 2 + i*12
+			if start+12 > len(tiff) {
+				break
+			}
+			e := tiff[start : start+12]
+			result = append(result, entry{order.Uint16(e), order.Uint16(e[2:]), order.Uint32(e[4:]), e[8:12]})
+		}
+		return result
+	}
+	var exifOffset uint32
+	for _, e := range entries(order.Uint32(tiff[4:])) {
+		switch e.tag {
+		case 0x0112: // Orientation, a SHORT
+			orientation = int(order.Uint16(e.value))
+		case 0x8769: // the offset of the EXIF IFD
+			exifOffset = order.Uint32(e.value)
+		}
+	}
+	if exifOffset == 0 {
+		return nil, orientation
+	}
+	for _, e := range entries(exifOffset) {
+		if e.tag == 0x9003 && e.typ == 2 && e.count >= 19 { // DateTimeOriginal, an ASCII string
+			if start := order.Uint32(e.value); uint64(start)+19 <= uint64(len(tiff)) {
+				if t, err := time.Parse("2006:01:02 15:04:05", string(tiff[start:start+19])); err == nil { // success
+					taken = &t
+				}
+			}
+		}
+	}
+	return taken, orientation
+}
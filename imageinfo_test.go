@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// jpegSegment returns a JPEG segment with the given marker and contents, and the length that is given in the header
+func jpegSegment(marker byte, length int, contents []byte) []byte {
+	return join([]byte{0xff, marker, byte(length >> 8), byte(length)}, contents)
+}
+
+func TestReadEXIF(t *testing.T) {
+	// A big-endian TIFF header and IFD0 with one entry, the orientation 6 (rotated 90° CW)
+	tiff := join([]byte("MM\x00\x2a"), be32(8), []byte{0x00, 0x01, 0x01, 0x12, 0x00, 0x03}, be32(1), []byte{0x00, 0x06, 0x00, 0x00}, be32(0))
+	exif := join([]byte("Exif\x00\x00"), tiff)
+	soi := []byte{0xff, 0xd8}
+	app0 := jpegSegment(0xe0, 16, join([]byte("JFIF\x00"), bytes.Repeat([]byte{0}, 9)))
+	sof0 := jpegSegment(0xc0, 17, bytes.Repeat([]byte{1}, 15))
+
+	for _, tc := range []struct {
+		name        string
+		data        []byte
+		orientation int
+	}{
+		{"valid", join(soi, app0, jpegSegment(0xe1, 2+len(exif), exif)), 6},
+		{"APP1 after SOF0", join(soi, app0, sof0, jpegSegment(0xe1, 2+len(exif), exif)), 6},
+		{"APP1 with length 0", join(soi, app0, sof0, jpegSegment(0xe1, 0, exif)), 0},
+		{"APP1 with length 1", join(soi, app0, jpegSegment(0xe1, 1, exif)), 0},
+		{"APP1 that is longer than the data", join(soi, app0, jpegSegment(0xe1, 0xffff, exif)), 0},
+		{"truncated TIFF header", join(soi, jpegSegment(0xe1, 2+10, exif[:10])), 0},
+		{"IFD offset past the end", join(soi, jpegSegment(0xe1, 2+14, join([]byte("Exif\x00\x00MM\x00\x2a"), be32(0xffffffff)))), 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, orientation := readEXIF(tc.data); orientation != tc.orientation {
+				t.Errorf("got orientation %d, want %d", orientation, tc.orientation)
+			}
+		})
+	}
+}
+
+// exifWithDate returns an APP1 segment with an EXIF IFD that has a DateTimeOriginal at the given offset in the TIFF data
+func exifWithDate(exifIFDOffset, dateOffset uint32) []byte {
+	// IFD0 at offset 8, with the offset of the EXIF IFD
+	tiff := join([]byte("MM\x00\x2a"), be32(8), []byte{0x00, 0x01, 0x87, 0x69, 0x00, 0x04}, be32(1), be32(exifIFDOffset), be32(0))
+	// The EXIF IFD at offset 26, with DateTimeOriginal, followed by the date at offset 44
+	tiff = join(tiff, []byte{0x00, 0x01, 0x90, 0x03, 0x00, 0x02}, be32(20), be32(dateOffset), be32(0), []byte("2021:07:04 12:34:56\x00"))
+	exif := join([]byte("Exif\x00\x00"), tiff)
+	return join([]byte{0xff, 0xd8}, jpegSegment(0xe1, 2+len(exif), exif))
+}
+
+// TestReadEXIFOffsets checks that offsets near the maximum uint32 do not
+// overflow the bounds checks, which matters on 32-bit platforms
+func TestReadEXIFOffsets(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		exifIFDOffset uint32
+		dateOffset    uint32
+		want          string
+	}{
+		{"valid", 26, 44, "2021-07-04 12:34"},
+		{"EXIF IFD offset past the end", 0xffffffff, 44, ""},
+		{"EXIF IFD offset just before the maximum", 0xfffffffe, 44, ""},
+		{"DateTimeOriginal offset past the end", 26, 0xffffffff, ""},
+		{"DateTimeOriginal offset that wraps around", 26, 0xfffffff0, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			taken, _ := readEXIF(exifWithDate(tc.exifIFDOffset, tc.dateOffset))
+			got := ""
+			if taken != nil {
+				got = taken.Format("2006-01-02 15:04")
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
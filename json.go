@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xyproto/mode"
+)
+
+// jsonSchemaVersion is increased whenever fields in the JSON output are
+// renamed, removed or change meaning. New fields may be added without
+// increasing the version. See README.md for a description of the schema.
+const jsonSchemaVersion = 1
+
+// JSONDocument is the document that is written by --json
+type JSONDocument struct {
+	SchemaVersion   int         `json:"schema_version"`
+	Version         string      `json:"version"`
+	Path            string      `json:"path"`
+	Files           []JSONFile  `json:"files"`
+	Directories     []string    `json:"directories"`
+	IgnoredCount    int         `json:"ignored_count"`
+	Errors          []JSONError `json:"errors"`
+	Truncated       bool        `json:"truncated"`
+	Interrupted     bool        `json:"interrupted"`
+	Git             *JSONGit    `json:"git"`
+	BuildSuggestion []string    `json:"build_suggestion"`
+	Stats           []TypeStats `json:"stats"`
+}
+
+// JSONFile is a single file, or an ignored entry, in the JSON output
+type JSONFile struct {
+	Path        string         `json:"path"`
+	Size        int64          `json:"size"`
+	Mode        string         `json:"mode"`
+	Description string         `json:"description"`
+	Binary      bool           `json:"binary"`
+	Format      string         `json:"format"`
+	MIME        string         `json:"mime"`
+	Lines       *int           `json:"lines"`          // null if the lines were not counted
+	Breakdown   *LineBreakdown `json:"line_breakdown"` // null if the lines were not counted, or for non-source files
+	Modified    time.Time      `json:"mtime"`
+	Ignored     bool           `json:"ignored"`
+	LinkTarget  string         `json:"link_target,omitempty"`
+	ELF         *ELFInfo       `json:"elf,omitempty"`
+	GoBuild     *GoBuildInfo   `json:"go,omitempty"`
+	Archive     *ArchiveInfo   `json:"archive,omitempty"`
+	Image       *ImageInfo     `json:"image,omitempty"`
+	Media       *MediaInfo     `json:"media,omitempty"`
+	Text        *TextFormat    `json:"text,omitempty"`
+}
+
+// JSONError is an entry that could not be read
+type JSONError struct {
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// JSONGit contains information about the git repository, if found
+type JSONGit struct {
+	URL          string      `json:"url"`
+	LatestCommit *JSONCommit `json:"latest_commit"`
+}
+
+// JSONCommit is a single git commit
+type JSONCommit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+}
+
+// newJSONFile converts a file row to a JSONFile
+func newJSONFile(row *FileRow) JSONFile {
+	jsonFile := JSONFile{
+		Path:        row.Path,
+		Size:        row.Size(),
+		Description: row.Type.Description,
+		Binary:      row.Type.IsBinary,
+		Format:      row.Type.Format,
+		MIME:        row.Type.MIME,
+		Modified:    row.Modified(),
+	}
+	if row.Type.Mode != mode.Blank {
+		jsonFile.Mode = row.Type.Mode.String()
+	}
+	if row.Type.LineCount >= 0 {
+		lineCount := row.Type.LineCount
+		jsonFile.Lines = &lineCount
+	}
+	jsonFile.Breakdown = row.Type.Breakdown
+	if row.Type.Link != nil {
+		jsonFile.LinkTarget = row.Type.Link.Target
+	}
+	jsonFile.ELF = row.Type.ELF
+	jsonFile.GoBuild = row.Type.GoBuild
+	jsonFile.Archive = row.Type.Archive
+	jsonFile.Image = row.Type.Image
+	jsonFile.Media = row.Type.Media
+	jsonFile.Text = row.Type.Text
+	return jsonFile
+}
+
+// newIgnoredJSONFile returns a JSONFile for an ignored entry, which is not analyzed
+func newIgnoredJSONFile(path string, fileInfo os.FileInfo) JSONFile {
+	jsonFile := JSONFile{Path: path, Ignored: true}
+	if fileInfo != nil {
+		jsonFile.Size = fileInfo.Size()
+		jsonFile.Modified = fileInfo.ModTime()
+	}
+	return jsonFile
+}
+
+// newJSONGit returns information about the git repository, or nil
+func newJSONGit(path string, git *Git) *JSONGit {
+	if git == nil {
+		return nil
+	}
+	jsonGit := &JSONGit{URL: git.URL}
+	if c, err := LatestCommit(path, time.Time{}); err == nil && c != nil { // success
+		jsonGit.LatestCommit = &JSONCommit{
+			Hash:    c.Hash.String(),
+			Author:  c.Author.Name,
+			Email:   c.Author.Email,
+			Date:    c.Author.When,
+			Message: strings.TrimSpace(c.Message),
+		}
+	}
+	return jsonGit
+}
+
+// NewJSONDocument collects the findings in a JSONDocument
+func (cfg *Config) NewJSONDocument(findings *Findings, buildSuggestion []string) *JSONDocument {
+	doc := &JSONDocument{
+		SchemaVersion:   jsonSchemaVersion,
+		Version:         versionString,
+		Path:            cfg.path,
+		Files:           make([]JSONFile, 0, len(findings.rows)+len(findings.ignoredFiles)),
+		Directories:     make([]string, 0, len(findings.dirList)),
+		IgnoredCount:    len(findings.ignoredFiles),
+		Errors:          make([]JSONError, 0, len(findings.walkErrors)),
+		Truncated:       findings.truncated,
+		Interrupted:     findings.interrupted,
+		BuildSuggestion: buildSuggestion,
+	}
+
+	SortRows(findings.rows, cfg.sortKey, cfg.reverse)
+	for i := range findings.rows {
+		doc.Files = append(doc.Files, newJSONFile(&findings.rows[i]))
+	}
+
+	sort.Strings(findings.ignoredFiles)
+	for _, fn := range findings.ignoredFiles {
+		doc.Files = append(doc.Files, newIgnoredJSONFile(fn, findings.infoMap[fn]))
+	}
+
+	sort.Strings(findings.dirList)
+	doc.Directories = append(doc.Directories, findings.dirList...)
+
+	for _, walkError := range findings.walkErrors {
+		doc.Errors = append(doc.Errors, JSONError{walkError.Path, walkError.Kind, walkError.Err.Error()})
+	}
+
+	doc.Git = newJSONGit(cfg.path, findings.git)
+
+	doc.Stats = ComputeStats(findings.rows).Sorted()
+
+	return doc
+}
+
+// WriteJSON writes the given value as indented JSON
+func WriteJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// largeFileBlockSize is the size of the first block of a large file, which is
+// used for detecting the file type, and of the buffer that is used when
+// counting lines
+const largeFileBlockSize = 64 * 1024
+
+// errLineCountTimeout is returned by StreamLineCount when the timeout is reached
+var errLineCountTimeout = errors.New("timeout while counting lines")
+
+// DetectLargeFileType detects the file type of a file that is too large to be
+// read into memory. Only the first block is used for detecting the file type.
+// If the file is not binary and not larger than maxLineCountSize, the lines
+// are counted by streaming through the file, for at most the given duration.
+// The code, comment and blank lines are not counted for large files.
+// Each step is recorded in the explanation, unless it is nil.
+func DetectLargeFileType(filename string, fileInfo os.FileInfo, maxLineCountSize int64, timeout time.Duration, explanation *Explanation) FileTypeInfo {
+	f, err := os.Open(filename)
+	if err != nil {
+		return detectFileType(filename, fileInfo, nil, explanation)
+	}
+	defer f.Close()
+
+	firstBlock := make([]byte, largeFileBlockSize)
+	n, err := io.ReadFull(f, firstBlock)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return detectFileType(filename, fileInfo, nil, explanation)
+	}
+	firstBlock = firstBlock[:n]
+
+	typeInfo := detectFileType(filename, fileInfo, firstBlock, explanation)
+	typeInfo.LineCount = -1
+	typeInfo.Breakdown = nil
+
+	// The line endings and indentation are only checked in the first block, but the last byte tells if there is a final newline
+	if typeInfo.Text != nil {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, fileInfo.Size()-1); err == nil { // success
+			typeInfo.Text.FinalNewline = last[0] == '\n' || last[0] == '\r'
+		}
+	}
+	if typeInfo.IsBinary || fileInfo.Size() > maxLineCountSize {
+		return typeInfo
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if lineCount, err := StreamLineCount(ctx, io.MultiReader(bytes.NewReader(firstBlock), f)); err == nil { // success
+		typeInfo.LineCount = lineCount
+	}
+	return typeInfo
+}
+
+// StreamLineCount counts the newlines in the given reader, one buffer at a
+// time. Returns errLineCountTimeout if ctx is done before the end is reached.
+func StreamLineCount(ctx context.Context, r io.Reader) (int, error) {
+	var (
+		lineCount int
+		buf       = make([]byte, largeFileBlockSize)
+	)
+	for {
+		if ctx.Err() != nil {
+			return 0, errLineCountTimeout
+		}
+		n, err := r.Read(buf)
+		lineCount += bytes.Count(buf[:n], []byte{'\n'})
+		if err == io.EOF {
+			return lineCount, nil
+		} else if err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/xyproto/mode"
+)
+
+// LineBreakdown is the number of code, comment and blank lines in a source file
+type LineBreakdown struct {
+	Code    int `json:"code"`
+	Comment int `json:"comment"`
+	Blank   int `json:"blank"`
+}
+
+// quote is a string delimiter. Comment markers within strings are not comments.
+type quote struct {
+	delim     string
+	multiline bool // the string may continue on the next line
+	escapes   bool // a backslash escapes the next character
+}
+
+// commentSyntax describes the comments and strings of a language
+type commentSyntax struct {
+	lineComments  []string
+	blockComments [][2]string // start and end markers
+	quotes        []quote
+}
+
+var (
+	doubleQuote  = quote{`"`, false, true}
+	singleQuote  = quote{`'`, false, true}
+	backtick     = quote{"`", true, false}
+	templateLit  = quote{"`", true, true}
+	tripleDouble = quote{`"""`, true, true}
+	tripleSingle = quote{`'''`, true, true}
+
+	cBlock = [2]string{"/*", "*/"}
+
+	cSyntax          = commentSyntax{[]string{"//"}, [][2]string{cBlock}, []quote{doubleQuote, singleQuote}}
+	goSyntax         = commentSyntax{[]string{"//"}, [][2]string{cBlock}, []quote{doubleQuote, singleQuote, backtick}}
+	javaScriptSyntax = commentSyntax{[]string{"//"}, [][2]string{cBlock}, []quote{doubleQuote, singleQuote, templateLit}}
+	rustSyntax       = commentSyntax{[]string{"//"}, [][2]string{cBlock}, []quote{doubleQuote}}
+	cssSyntax        = commentSyntax{nil, [][2]string{cBlock}, []quote{doubleQuote, singleQuote}}
+	hashSyntax       = commentSyntax{[]string{"#"}, nil, []quote{doubleQuote, singleQuote}}
+	pythonSyntax     = commentSyntax{[]string{"#"}, nil, []quote{tripleDouble, tripleSingle, doubleQuote, singleQuote}}
+	nimSyntax        = commentSyntax{[]string{"#"}, [][2]string{{"#[", "]#"}}, []quote{tripleDouble, doubleQuote}}
+	phpSyntax        = commentSyntax{[]string{"//", "#"}, [][2]string{cBlock}, []quote{doubleQuote, singleQuote}}
+	sqlSyntax        = commentSyntax{[]string{"--"}, [][2]string{cBlock}, []quote{doubleQuote, singleQuote}}
+	luaSyntax        = commentSyntax{[]string{"--"}, [][2]string{{"--[[", "]]"}}, []quote{doubleQuote, singleQuote}}
+	haskellSyntax    = commentSyntax{[]string{"--"}, [][2]string{{"{-", "-}"}}, []quote{doubleQuote}}
+	adaSyntax        = commentSyntax{[]string{"--"}, nil, []quote{doubleQuote}}
+	lispSyntax       = commentSyntax{[]string{";"}, [][2]string{{"#|", "|#"}}, []quote{doubleQuote}}
+	iniSyntax        = commentSyntax{[]string{";", "#"}, nil, []quote{doubleQuote}}
+	assemblySyntax   = commentSyntax{[]string{";"}, nil, []quote{doubleQuote, singleQuote}}
+	erlangSyntax     = commentSyntax{[]string{"%"}, nil, []quote{doubleQuote}}
+	prologSyntax     = commentSyntax{[]string{"%"}, [][2]string{cBlock}, []quote{doubleQuote, singleQuote}}
+	ocamlSyntax      = commentSyntax{nil, [][2]string{{"(*", "*)"}}, []quote{doubleQuote}}
+	fsharpSyntax     = commentSyntax{[]string{"//"}, [][2]string{{"(*", "*)"}}, []quote{doubleQuote}}
+	pascalSyntax     = commentSyntax{[]string{"//"}, [][2]string{{"{", "}"}, {"(*", "*)"}}, []quote{singleQuote}}
+	fortranSyntax    = commentSyntax{[]string{"!"}, nil, []quote{doubleQuote, singleQuote}}
+	xmlSyntax        = commentSyntax{nil, [][2]string{{"<!--", "-->"}}, nil}
+)
+
+// commentSyntaxFor returns the comment syntax for the given mode, or false
+// if the mode is not a source code format with known comment syntax
+func commentSyntaxFor(m mode.Mode) (commentSyntax, bool) {
+	switch m {
+	case mode.C, mode.Cpp, mode.CS, mode.Java, mode.Kotlin, mode.Swift, mode.Scala, mode.Dart, mode.D,
+		mode.ObjC, mode.Arduino, mode.Shader, mode.Haxe, mode.Zig, mode.V, mode.Odin, mode.Hare, mode.C3,
+		mode.Jakt, mode.Gradle, mode.AIDL, mode.HIDL, mode.GoAssembly:
+		return cSyntax, true
+	case mode.Go, mode.GoMod:
+		return goSyntax, true
+	case mode.JavaScript, mode.TypeScript:
+		return javaScriptSyntax, true
+	case mode.Rust:
+		return rustSyntax, true
+	case mode.CSS:
+		return cssSyntax, true
+	case mode.Shell, mode.Perl, mode.Ruby, mode.Make, mode.CMake, mode.Config, mode.Docker, mode.R,
+		mode.Crystal, mode.Bazel, mode.Starlark, mode.Just, mode.GDScript, mode.Inko, mode.Mojo, mode.Ollama:
+		return hashSyntax, true
+	case mode.Python:
+		return pythonSyntax, true
+	case mode.Nim:
+		return nimSyntax, true
+	case mode.PHP:
+		return phpSyntax, true
+	case mode.SQL:
+		return sqlSyntax, true
+	case mode.Lua, mode.Teal, mode.Terra:
+		return luaSyntax, true
+	case mode.Haskell, mode.Elm, mode.Agda:
+		return haskellSyntax, true
+	case mode.Ada:
+		return adaSyntax, true
+	case mode.Lisp, mode.Clojure, mode.Scheme:
+		return lispSyntax, true
+	case mode.Ini:
+		return iniSyntax, true
+	case mode.Assembly:
+		return assemblySyntax, true
+	case mode.Erlang:
+		return erlangSyntax, true
+	case mode.Prolog:
+		return prologSyntax, true
+	case mode.OCaml, mode.StandardML:
+		return ocamlSyntax, true
+	case mode.FSharp:
+		return fsharpSyntax, true
+	case mode.ObjectPascal:
+		return pascalSyntax, true
+	case mode.Fortran90:
+		return fortranSyntax, true
+	case mode.HTML, mode.XML:
+		return xmlSyntax, true
+	}
+	return commentSyntax{}, false
+}
+
+// CountLines counts the code, comment and blank lines in the given source
+// code. Lines with both code and a comment are counted as code. Comment
+// markers within strings are skipped, and multiline strings are counted as
+// code, also when they contain blank lines.
+func CountLines(data []byte, syntax commentSyntax) LineBreakdown {
+	var (
+		breakdown LineBreakdown
+		blockEnd  string // set while within a block comment
+		inQuote   *quote // set while within a multiline string
+	)
+	for len(data) > 0 {
+		var line []byte
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			line, data = data[:idx], data[idx+1:]
+		} else {
+			line, data = data, nil
+		}
+		hasCode, hasComment := inQuote != nil, blockEnd != ""
+		if len(bytes.TrimSpace(line)) == 0 {
+			if hasCode {
+				breakdown.Code++
+			} else {
+				breakdown.Blank++
+			}
+			continue
+		}
+	scan:
+		for i := 0; i < len(line); {
+			switch {
+			case blockEnd != "":
+				// Look for the end of the block comment
+				idx := bytes.Index(line[i:], []byte(blockEnd))
+				if idx < 0 {
+					break scan
+				}
+				i += idx + len(blockEnd)
+				blockEnd = ""
+			case inQuote != nil:
+				// Look for the end of the string
+				if inQuote.escapes && line[i] == '\\' {
+					i += 2
+				} else if bytes.HasPrefix(line[i:], []byte(inQuote.delim)) {
+					i += len(inQuote.delim)
+					inQuote = nil
+				} else {
+					i++
+				}
+			case line[i] == ' ' || line[i] == '\t' || line[i] == '\r':
+				i++
+			default:
+				// Block comments are checked first, since "--[[" starts with "--"
+				rest := line[i:]
+				if start, end, ok := blockCommentAt(rest, syntax); ok {
+					hasComment = true
+					blockEnd = end
+					i += len(start)
+					continue
+				}
+				for _, marker := range syntax.lineComments {
+					if bytes.HasPrefix(rest, []byte(marker)) {
+						hasComment = true
+						break scan
+					}
+				}
+				hasCode = true
+				if q := quoteAt(rest, syntax); q != nil {
+					inQuote = q
+					i += len(q.delim)
+					continue
+				}
+				i++
+			}
+		}
+		// Strings that can not span lines end at the end of the line
+		if inQuote != nil && !inQuote.multiline {
+			inQuote = nil
+		}
+		switch {
+		case hasCode:
+			breakdown.Code++
+		case hasComment:
+			breakdown.Comment++
+		default:
+			breakdown.Blank++
+		}
+	}
+	return breakdown
+}
+
+// blockCommentAt checks if a block comment starts at the beginning of the given bytes
+func blockCommentAt(b []byte, syntax commentSyntax) (start, end string, ok bool) {
+	for _, block := range syntax.blockComments {
+		if bytes.HasPrefix(b, []byte(block[0])) {
+			return block[0], block[1], true
+		}
+	}
+	return "", "", false
+}
+
+// quoteAt checks if a string starts at the beginning of the given bytes
+func quoteAt(b []byte, syntax commentSyntax) *quote {
+	for i := range syntax.quotes {
+		if bytes.HasPrefix(b, []byte(syntax.quotes[i].delim)) {
+			return &syntax.quotes[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestCountLines(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		syntax commentSyntax
+		source string
+		want   LineBreakdown
+	}{
+		{
+			"line comment markers within strings",
+			goSyntax,
+			"url := \"http://example.com\"\ns := \"//\" // a comment\n// only a comment\n",
+			LineBreakdown{Code: 2, Comment: 1},
+		},
+		{
+			"escaped quotes",
+			cSyntax,
+			"puts(\"\\\" // still a string\");\n",
+			LineBreakdown{Code: 1},
+		},
+		{
+			"Lua block comments and line comments",
+			luaSyntax,
+			"--[[ a block comment\n\nthat spans lines ]]\n-- a line comment\nlocal x = 1 -- a trailing comment\n--[[ short ]] local y = 2\n",
+			LineBreakdown{Code: 2, Comment: 3, Blank: 1},
+		},
+		{
+			"Go raw strings with comment markers and blank lines",
+			goSyntax,
+			"s := `a /* not a comment\n\n// and not this`\nx := 1\n",
+			LineBreakdown{Code: 4},
+		},
+		{
+			"a block comment that starts after code",
+			cSyntax,
+			"int x; /* starts here\n\n   ends here */\nint y;\n",
+			LineBreakdown{Code: 2, Comment: 1, Blank: 1},
+		},
+		{
+			"Python docstrings",
+			pythonSyntax,
+			"def f():\n    \"\"\"# not a comment\n\n    \"\"\"\n    # a comment\n",
+			LineBreakdown{Code: 4, Comment: 1},
+		},
+		{
+			"a last line without a final newline",
+			goSyntax,
+			"x := 1\n\n// the end",
+			LineBreakdown{Code: 1, Comment: 1, Blank: 1},
+		},
+		{
+			"a last line with only whitespace and no final newline",
+			hashSyntax,
+			"echo hi\n  ",
+			LineBreakdown{Code: 1, Blank: 1},
+		},
+		{
+			"no lines",
+			cSyntax,
+			"",
+			LineBreakdown{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CountLines([]byte(tc.source), tc.syntax); got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
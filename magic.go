@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+)
+
+// Signature is a binary file format that can be recognized by its header bytes
+type Signature struct {
+	Format      string // a short name for the format, like "PNG"
+	Description string // the description that is shown in the listing, like "PNG image"
+	offset      int    // the offset of the magic bytes
+	magic       []byte
+	weak        bool                                  // the magic bytes are short or common, so the data must also look binary
+	refine      func(data []byte, name string) string // returns a more specific format, or ""
+	verify      func(data []byte) bool                // checks more than the magic bytes, or nil
+}
+
+// signatures is the signature database. The first matching signature is used.
+// Formats that share magic bytes are told apart by the refine functions.
+var signatures = []*Signature{
+	// Executables and object code
+	{Format: "ELF", Description: "ELF binary", magic: []byte("\x7fELF")},
+	{Format: "PE", Description: "DOS executable", magic: []byte("MZ"), weak: true, refine: refinePE},
+	{Format: "Mach-O", Description: "Mach-O binary", magic: []byte{0xfe, 0xed, 0xfa, 0xce}},
+	{Format: "Mach-O", Description: "Mach-O binary", magic: []byte{0xfe, 0xed, 0xfa, 0xcf}},
+	{Format: "Mach-O", Description: "Mach-O binary", magic: []byte{0xce, 0xfa, 0xed, 0xfe}},
+	{Format: "Mach-O", Description: "Mach-O binary", magic: []byte{0xcf, 0xfa, 0xed, 0xfe}},
+	{Format: "Mach-O", Description: "Mach-O universal binary", magic: []byte{0xca, 0xfe, 0xba, 0xbe}, refine: refineCafebabe},
+	{Format: "WebAssembly", Description: "WebAssembly module", magic: []byte("\x00asm")},
+
+	// Images
+	{Format: "PNG", Description: "PNG image", magic: []byte("\x89PNG\r\n\x1a\n")},
+	{Format: "JPEG", Description: "JPEG image", magic: []byte{0xff, 0xd8, 0xff}},
+	{Format: "GIF", Description: "GIF image", magic: []byte("GIF87a")},
+	{Format: "GIF", Description: "GIF image", magic: []byte("GIF89a")},
+	{Format: "RIFF", Description: "RIFF data", magic: []byte("RIFF"), refine: refineRIFF},
+
+	// Documents and databases
+	{Format: "PDF", Description: "PDF document", magic: []byte("%PDF-")},
+	{Format: "SQLite", Description: "SQLite database", magic: []byte("SQLite format 3\x00")},
+
+	// Archives and compressed data
+	{Format: "ZIP", Description: "Zip archive", magic: []byte("PK\x03\x04"), refine: refineZIP},
+	{Format: "ZIP", Description: "Zip archive", magic: []byte("PK\x05\x06")},
+	{Format: "Gzip", Description: "Gzip compressed data", magic: []byte{0x1f, 0x8b}},
+	{Format: "XZ", Description: "XZ compressed data", magic: []byte("\xfd7zXZ\x00")},
+	{Format: "Zstandard", Description: "Zstandard compressed data", magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{Format: "Bzip2", Description: "Bzip2 compressed data", offset: 4, magic: []byte("1AY&SY")}, // the block header, after "BZh" and the block size
+	{Format: "7-Zip", Description: "7-Zip archive", magic: []byte("7z\xbc\xaf\x27\x1c")},
+	{Format: "Tar", Description: "Tar archive", offset: 257, magic: []byte("ustar")},
+
+	// Fonts
+	{Format: "TrueType", Description: "TrueType font", magic: []byte{0x00, 0x01, 0x00, 0x00, 0x00}, weak: true},
+	{Format: "TrueType", Description: "TrueType font", magic: []byte("true\x00"), weak: true},
+	{Format: "TrueType", Description: "TrueType font collection", magic: []byte("ttcf")},
+	{Format: "OpenType", Description: "OpenType font", magic: []byte("OTTO\x00")},
+	{Format: "WOFF", Description: "WOFF font", magic: []byte("wOFF")},
+	{Format: "WOFF2", Description: "WOFF2 font", magic: []byte("wOF2")},
+
+	// Media containers
+	{Format: "MP4", Description: "MP4 video", offset: 4, magic: []byte("ftyp"), refine: refineISOBMFF},
+	{Format: "Matroska", Description: "Matroska video", magic: []byte{0x1a, 0x45, 0xdf, 0xa3}, refine: refineEBML},
+	{Format: "Ogg", Description: "Ogg media", magic: []byte("OggS")},
+	{Format: "FLAC", Description: "FLAC audio", magic: []byte("fLaC")},
+	{Format: "MP3", Description: "MP3 audio", magic: []byte("ID3\x02")},
+	{Format: "MP3", Description: "MP3 audio", magic: []byte("ID3\x03")},
+	{Format: "MP3", Description: "MP3 audio", magic: []byte("ID3\x04")},
+	{Format: "MP3", Description: "MP3 audio", magic: []byte{0xff, 0xfb}, weak: true},
+	{Format: "MP3", Description: "MP3 audio", magic: []byte{0xff, 0xf3}, weak: true},
+	{Format: "MP3", Description: "MP3 audio", magic: []byte{0xff, 0xf2}, weak: true},
+	{Format: "MIDI", Description: "MIDI audio", magic: []byte("MThd\x00\x00\x00\x06")},
+	{Format: "MPEG-TS", Description: "MPEG transport stream", magic: []byte{0x47}, weak: true, verify: verifyMPEGTS},
+	{Format: "MPEG-PS", Description: "MPEG program stream", magic: []byte{0x00, 0x00, 0x01, 0xba}},
+}
+
+// DetectSignature looks up the given header bytes in the signature database.
+// The filename is used for telling apart formats that share the same magic
+// bytes, like Zip and JAR files. isBinary is the result of the binary
+// detection, which is required for signatures with short magic bytes.
+// Returns nil if no signature matches.
+func DetectSignature(filename string, data []byte, isBinary bool) *Signature {
+	name := strings.ToLower(filepath.Base(filename))
+	for _, sig := range signatures {
+		if sig.weak && !isBinary {
+			continue
+		}
+		if len(data) < sig.offset+len(sig.magic) || !bytes.Equal(data[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
+			continue
+		}
+		if sig.verify != nil && !sig.verify(data) {
+			continue
+		}
+		if sig.refine != nil {
+			if format := sig.refine(data, name); format != "" {
+				if refined, ok := refinedSignatures[format]; ok {
+					return refined
+				}
+			}
+		}
+		return sig
+	}
+	return nil
+}
+
+// refinedSignatures are the formats that are returned by the refine functions
+var refinedSignatures = map[string]*Signature{
+	"PE":        {Format: "PE", Description: "PE executable"},
+	"JavaClass": {Format: "JavaClass", Description: "Java class file"},
+	"WebP":      {Format: "WebP", Description: "WebP image"},
+	"WAV":       {Format: "WAV", Description: "WAV audio"},
+	"AVI":       {Format: "AVI", Description: "AVI video"},
+	"JAR":       {Format: "JAR", Description: "JAR archive"},
+	"APK":       {Format: "APK", Description: "Android package"},
+	"QuickTime": {Format: "QuickTime", Description: "QuickTime video"},
+	"M4A":       {Format: "M4A", Description: "MPEG-4 audio"},
+	"HEIF":      {Format: "HEIF", Description: "HEIF image"},
+	"AVIF":      {Format: "AVIF", Description: "AVIF image"},
+	"WebM":      {Format: "WebM", Description: "WebM video"},
+}
+
+// refinePE checks if a DOS executable has a PE header
+func refinePE(data []byte, _ string) string {
+	if len(data) < 0x40 {
+		return ""
+	}
+	offset := int(binary.LittleEndian.Uint32(data[0x3c:]))
+	if offset > 0 && offset+4 <= len(data) && bytes.Equal(data[offset:offset+4], []byte("PE\x00\x00")) {
+		return "PE"
+	}
+	return ""
+}
+
+// refineCafebabe tells Java class files and Mach-O universal binaries apart.
+// Both start with 0xcafebabe, but class files have a major version of 45 or
+// more where universal binaries have a small number of architectures.
+func refineCafebabe(data []byte, _ string) string {
+	if len(data) >= 8 && binary.BigEndian.Uint16(data[6:8]) >= 45 {
+		return "JavaClass"
+	}
+	return ""
+}
+
+// refineRIFF returns the format of a RIFF container
+func refineRIFF(data []byte, _ string) string {
+	if len(data) < 12 {
+		return ""
+	}
+	switch string(data[8:12]) {
+	case "WEBP":
+		return "WebP"
+	case "WAVE":
+		return "WAV"
+	case "AVI ":
+		return "AVI"
+	}
+	return ""
+}
+
+// refineZIP tells Zip based formats apart, by the filename or the name of the first entry
+func refineZIP(data []byte, name string) string {
+	var firstEntry string
+	if len(data) >= 30 {
+		nameLength := int(binary.LittleEndian.Uint16(data[26:28]))
+		if 30+nameLength <= len(data) {
+			firstEntry = string(data[30 : 30+nameLength])
+		}
+	}
+	switch {
+	case strings.HasSuffix(name, ".apk") || firstEntry == "AndroidManifest.xml":
+		return "APK"
+	case strings.HasSuffix(name, ".jar") || strings.HasPrefix(firstEntry, "META-INF/"):
+		return "JAR"
+	}
+	return ""
+}
+
+// refineISOBMFF returns the format of an ISO base media file, like MP4, by the major brand
+func refineISOBMFF(data []byte, _ string) string {
+	if len(data) < 12 {
+		return ""
+	}
+	switch string(data[8:12]) {
+	case "qt  ":
+		return "QuickTime"
+	case "M4A ", "M4B ":
+		return "M4A"
+	case "heic", "heix", "mif1", "msf1":
+		return "HEIF"
+	case "avif", "avis":
+		return "AVIF"
+	}
+	return ""
+}
+
+// refineEBML checks if a Matroska file has the "webm" document type
+func refineEBML(data []byte, _ string) string {
+	header := data[:min(len(data), 64)]
+	if bytes.Contains(header, []byte("webm")) {
+		return "WebM"
+	}
+	return ""
+}
+
+// mpegTSPacketSize is the size of the packets in an MPEG transport stream, which all start with 0x47
+const mpegTSPacketSize = 188
+
+// verifyMPEGTS checks that the next packets of an MPEG transport stream also start with the sync byte
+func verifyMPEGTS(data []byte) bool {
+	if len(data) < 3*mpegTSPacketSize {
+		return false
+	}
+	for i := mpegTSPacketSize; i < len(data) && i <= 4*mpegTSPacketSize; i += mpegTSPacketSize {
+		if data[i] != 0x47 {
+			return false
+		}
+	}
+	return true
+}
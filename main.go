@@ -1,41 +1,71 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
 	"github.com/dustin/go-humanize"
 	"github.com/dustin/go-humanize/english"
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/spf13/cobra"
 	"github.com/xyproto/mode"
 	"github.com/xyproto/textoutput"
+	"github.com/xyproto/vt100"
 )
 
 const (
 	versionString        = "pal 0.2.6"
 	defaultPath          = "."
 	defaultMaxDepth      = 1
-	defaultReadThreshold = 10 * 1042 * 1024 // 10 MiB file size
-	defaultLineThreshold = 1 * 1024 * 1024  // 1 MiB file size
+	defaultReadThreshold = "10MiB"         // larger files are not read into memory
+	defaultLineThreshold = "1GiB"          // lines are not counted for larger files
+	defaultLineTimeout   = 5 * time.Second // the maximum time spent counting the lines of a large file
+	entryBufferSize      = 256             // entries that can be found ahead of the file analysis
 )
 
 type Config struct {
 	maxDepth              int
+	minDepth              int
+	maxEntries            int
+	depthFlagSet          bool
+	sortKey               string
+	reverse               bool
 	path                  string
 	showAll               bool
 	respectIgnored        bool
 	respectHidden         bool
-	readFileSizeThreshold int64
-	lineCountThreshold    int64
+	followSymlinks        bool
+	verbose               bool
+	jsonOutput            bool
+	ndjsonOutput          bool
+	csvOutput             bool
+	tsvOutput             bool
+	columns               []string
+	color                 string // "auto", "always" or "never"
+	colors                bool   // the result of the color setting
+	tree                  bool
+	stats                 bool
+	archiveList           bool
+	termWidth             int    // the width of the terminal, or 0 if not writing to a terminal
+	readThreshold         string // the --read-threshold setting
+	lineThreshold         string // the --line-threshold setting
+	readFileSizeThreshold int64  // larger files are only partially read, and their lines are streamed
+	lineCountThreshold    int64  // the lines of larger files are not counted
+	lineCountTimeout      time.Duration
+	typesFile             string // the --types setting, or "" for the default location
+	explain               string // the path that is given with --explain, or ""
 	ollama                bool
 }
 
@@ -53,33 +83,133 @@ func parseHumanSize(sizeStr string) (int64, error) {
 	return int64(size), nil
 }
 
+// parseDepth parses a depth argument, where -1 means unlimited
+func parseDepth(s string) (int, bool) {
+	depth, err := strconv.Atoi(s)
+	if err != nil || depth < -1 {
+		return 0, false
+	}
+	return depth, true
+}
+
 func processArgs(cfg *Config, args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args[2:], " "))
+	}
+
+	// The path and depth arguments can be given in any order. A number is a
+	// depth, unless it is also the name of an existing path, in which case
+	// the other argument or the --depth flag decides.
+	var pathArgs, depthArgs, ambiguousArgs []string
+	for _, arg := range args {
+		_, isDepth := parseDepth(arg)
+		_, statErr := os.Stat(arg)
+		isPath := statErr == nil
+		switch {
+		case isDepth && isPath:
+			ambiguousArgs = append(ambiguousArgs, arg)
+		case isDepth:
+			depthArgs = append(depthArgs, arg)
+		default:
+			pathArgs = append(pathArgs, arg)
+		}
+	}
+	for _, arg := range ambiguousArgs {
+		if cfg.depthFlagSet && len(pathArgs) == 0 {
+			pathArgs = append(pathArgs, arg)
+		} else if len(depthArgs) == 0 && len(pathArgs) > 0 {
+			depthArgs = append(depthArgs, arg)
+		} else if len(pathArgs) == 0 && len(depthArgs) > 0 {
+			pathArgs = append(pathArgs, arg)
+		} else {
+			return fmt.Errorf("%s is both a depth and a path, use --depth %s or ./%s", arg, arg, arg)
+		}
+	}
+	if len(pathArgs) > 1 {
+		return fmt.Errorf("only one path can be given, got: %s", strings.Join(pathArgs, " "))
+	}
+	if len(depthArgs) > 1 {
+		return fmt.Errorf("only one depth can be given, got: %s", strings.Join(depthArgs, " "))
+	}
+
 	// Handle optional path argument
-	if len(args) > 0 {
+	if len(pathArgs) > 0 {
 		// Clean and make the path absolute if possible
-		path, err := filepath.Abs(args[0])
+		path, err := filepath.Abs(pathArgs[0])
 		if err == nil {
 			cfg.path = path
 		} else {
-			cfg.path = args[0] // Fallback to the provided path
+			cfg.path = pathArgs[0] // Fallback to the provided path
 		}
-		args = args[1:] // Remove processed argument
 	}
 
 	// Handle optional depth argument
-	if len(args) > 0 {
-		if depth, err := strconv.Atoi(args[0]); err == nil {
-			if depth < 0 {
-				return fmt.Errorf("depth must be a non-negative number")
-			}
-			cfg.maxDepth = depth
-			args = args[1:] // Remove processed argument
+	if len(depthArgs) > 0 {
+		if cfg.depthFlagSet {
+			return fmt.Errorf("the depth is given both as an argument and with --depth")
 		}
+		cfg.maxDepth, _ = parseDepth(depthArgs[0])
 	}
 
-	// Check for unexpected arguments
-	if len(args) > 0 {
-		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	// Validate the depth settings
+	if cfg.maxDepth < -1 {
+		return fmt.Errorf("depth must be -1 (unlimited) or a non-negative number")
+	}
+	if cfg.minDepth < 0 {
+		return fmt.Errorf("min-depth must be a non-negative number")
+	}
+	if cfg.maxDepth >= 0 && cfg.minDepth > cfg.maxDepth {
+		return fmt.Errorf("min-depth (%d) can not be larger than the depth (%d)", cfg.minDepth, cfg.maxDepth)
+	}
+	if cfg.maxEntries < 0 {
+		return fmt.Errorf("max-entries must be a non-negative number")
+	}
+
+	if !slices.Contains(sortKeys, cfg.sortKey) {
+		return fmt.Errorf("invalid sort key: %s (use one of: %s)", cfg.sortKey, strings.Join(sortKeys, ", "))
+	}
+
+	for _, column := range cfg.columns {
+		if !slices.Contains(csvColumns, column) {
+			return fmt.Errorf("invalid column: %s (use any of: %s)", column, strings.Join(csvColumns, ", "))
+		}
+	}
+
+	var err error
+	if cfg.readFileSizeThreshold, err = parseHumanSize(cfg.readThreshold); err != nil {
+		return fmt.Errorf("read-threshold: %w", err)
+	}
+	if cfg.lineCountThreshold, err = parseHumanSize(cfg.lineThreshold); err != nil {
+		return fmt.Errorf("line-threshold: %w", err)
+	}
+	if cfg.lineCountTimeout <= 0 {
+		return fmt.Errorf("line-timeout must be a positive duration")
+	}
+
+	// Load the user-defined type rules. The file at the default location is optional.
+	if cfg.typesFile != "" {
+		if typeRules, err = LoadTypeRules(cfg.typesFile); err != nil {
+			return err
+		}
+	} else if filename := defaultTypeRulesPath(); filename != "" {
+		if typeRules, err = LoadTypeRules(filename); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	switch cfg.color {
+	case "always":
+		cfg.colors = true
+	case "never":
+		cfg.colors = false
+	case "auto":
+		cfg.colors = !textoutput.EnvNoColor && isTerminal(os.Stdout)
+	default:
+		return fmt.Errorf("invalid color setting: %s (use auto, always or never)", cfg.color)
+	}
+
+	if isTerminal(os.Stdout) {
+		cfg.termWidth = vt100.ScreenWidth()
 	}
 
 	// Update config based on flags
@@ -99,29 +229,54 @@ func processArgs(cfg *Config, args []string) error {
 	return nil
 }
 
+// isTerminal checks if the given file is a terminal, and not a pipe or a regular file
+func isTerminal(f *os.File) bool {
+	fileInfo, err := f.Stat()
+	return err == nil && fileInfo.Mode()&os.ModeCharDevice != 0
+}
+
+// examineOptions returns the options for Examine and ExamineStream
+func (cfg *Config) examineOptions() ExamineOptions {
+	return ExamineOptions{
+		RespectIgnoreFiles: cfg.respectIgnored,
+		RespectHiddenFiles: cfg.respectHidden,
+		FollowSymlinks:     cfg.followSymlinks,
+		MinDepth:           cfg.minDepth,
+		MaxDepth:           cfg.maxDepth,
+		MaxEntries:         cfg.maxEntries,
+	}
+}
+
 func NewRootCommand() *cobra.Command {
 	cfg := &Config{
-		maxDepth:              defaultMaxDepth,
-		path:                  defaultPath,
-		respectIgnored:        true,
-		respectHidden:         true,
-		readFileSizeThreshold: defaultReadThreshold,
-		lineCountThreshold:    defaultLineThreshold,
-		ollama:                false,
+		maxDepth:       defaultMaxDepth,
+		path:           defaultPath,
+		respectIgnored: true,
+		respectHidden:  true,
+		ollama:         false,
 	}
 
 	cmd := &cobra.Command{
-		Use:   "pal [depth] [path]",
+		Use:   "pal [path] [depth]",
 		Short: "List and analyze files in a directory",
 		Long: `pal can list information about a directory
 
+The path and depth arguments can be given in any order.
+Top-level entries have depth 1, and a depth of -1 means unlimited.
+Since -1 looks like a flag, give it with --depth -1, or after --.
+
 Example use:
   pal                     # Examine the current directory with depth 1
   pal . 2                 # Examine the current directory with depth 2
-  pal /path/to/dir 2      # Examine the specified directory with depth 2
-  pal /path/to/dir        # Examine the specified directory with depth 1`,
+  pal 2 /path/to/dir      # Examine the specified directory with depth 2
+  pal /path/to/dir        # Examine the specified directory with depth 1
+  pal --depth -1          # Examine the current directory with unlimited depth
+  pal -- . -1             # The same, with -1 as an argument
+  pal --min-depth 2 . 3   # Only list entries at depth 2 and 3
+  pal -L . 3              # Also descend into symlinked directories`,
 		Version: versionString,
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.depthFlagSet = cmd.Flags().Changed("depth")
 			if err := processArgs(cfg, args); err != nil {
 				return err
 			}
@@ -131,83 +286,142 @@ Example use:
 
 	flags := cmd.Flags()
 	flags.BoolVarP(&cfg.showAll, "all", "a", false, "show all files (including hidden and ignored)")
+	flags.IntVarP(&cfg.maxDepth, "depth", "d", defaultMaxDepth, "maximum depth to list, -1 for unlimited")
+	flags.IntVar(&cfg.minDepth, "min-depth", 0, "minimum depth to list")
+	flags.IntVar(&cfg.maxEntries, "max-entries", 0, "stop after listing this many entries, 0 for unlimited")
+	flags.StringVarP(&cfg.sortKey, "sort", "s", "mtime", "sort files by "+strings.Join(sortKeys, ", "))
+	flags.BoolVarP(&cfg.reverse, "reverse", "r", false, "reverse the sort order")
+	flags.BoolVarP(&cfg.followSymlinks, "follow", "L", false, "descend into symlinked directories")
+	flags.BoolVar(&cfg.verbose, "verbose", false, "show details about entries that could not be read, the libraries needed by ELF files and the build information of Go binaries")
+	flags.StringVar(&cfg.color, "color", "auto", "use colors: auto, always or never (auto respects NO_COLOR and disables colors when not writing to a terminal)")
+	flags.BoolVarP(&cfg.tree, "tree", "t", false, "show the files and directories as a tree")
+	flags.BoolVar(&cfg.archiveList, "archive-list", false, "list the contents of zip and tar archives")
+	flags.BoolVar(&cfg.stats, "stats", false, "show the number of files, lines and bytes for each file type")
+	flags.StringVar(&cfg.readThreshold, "read-threshold", defaultReadThreshold, "larger files are not read into memory, but their lines are still counted")
+	flags.StringVar(&cfg.lineThreshold, "line-threshold", defaultLineThreshold, "do not count the lines of files larger than this")
+	flags.DurationVar(&cfg.lineCountTimeout, "line-timeout", defaultLineTimeout, "the maximum time spent counting the lines of each large file")
+	flags.StringVar(&cfg.explain, "explain", "", "explain how the file type of the given path is detected, step by step")
+	flags.StringVar(&cfg.typesFile, "types", "", "read user-defined file type rules from this JSON file (default ~/.config/pal/"+typeRulesFilename+")")
+	flags.BoolVar(&cfg.jsonOutput, "json", false, "output the findings as JSON")
+	flags.BoolVar(&cfg.ndjsonOutput, "ndjson", false, "output one JSON record per line, while the files are being analyzed")
+	flags.BoolVar(&cfg.csvOutput, "csv", false, "output the files as comma separated values")
+	flags.BoolVar(&cfg.tsvOutput, "tsv", false, "output the files as tab separated values")
+	flags.StringSliceVar(&cfg.columns, "columns", strings.Split(defaultCSVColumns, ","), "columns for --csv and --tsv: "+strings.Join(csvColumns, ", "))
+	cmd.MarkFlagsMutuallyExclusive("json", "ndjson", "csv", "tsv", "tree")
 	flags.BoolVarP(&cfg.ollama, "ollama", "o", false, "use ollama to suggest a build command")
 
+	// A depth of -1 is parsed as a shorthand flag, so explain how to give it
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		if _, arg, found := strings.Cut(err.Error(), " in "); found {
+			if _, isDepth := parseDepth(arg); isDepth {
+				return fmt.Errorf("%w (use --depth %s, or give the arguments after --)", err, arg)
+			}
+		}
+		return err
+	})
+
 	// Configure version flag
 	cmd.SetVersionTemplate(versionString + "\n")
 
 	return cmd
 }
 
-func (cfg *Config) AnalyzeFiles(ob *strings.Builder, findings *Findings, needsSeparator *bool) error {
-	findings.printMap = make(map[time.Time]string)
-	if *needsSeparator {
-		ob.WriteString("\n")
-		*needsSeparator = false
-	}
-	// In the file processing loop, use cfg.readFileSizeThreshold:
-	for _, fn := range findings.regularFiles {
-		fInfo, ok := findings.infoMap[fn]
-		if !ok {
-			ob.WriteString(fmt.Sprintf("<white>%s</white>\n", fn))
-			*needsSeparator = true
-			continue
-		}
-		// Read file contents if it's small enough
-		var fileContents []byte
-		if fInfo.Size() < cfg.readFileSizeThreshold {
-			if data, err := os.ReadFile(fn); err == nil {
-				fileContents = data
+// AnalyzeFiles receives entries from ExamineStream, stores them in the findings
+// and detects the file type of the regular files, while the walk is running.
+func (cfg *Config) AnalyzeFiles(entries <-chan Entry, findings *Findings) {
+	var wg sync.WaitGroup
+	for range runtime.GOMAXPROCS(0) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range entries {
+				findings.Add(entry)
+				if entry.Err == nil && !entry.Ignored {
+					cfg.analyzeFile(entry.Path, entry.Info, findings)
+				}
 			}
+		}()
+	}
+	wg.Wait()
+}
+
+// detectFileType reads the given file, if it is small enough, and detects the
+// file type. Each step is recorded in the explanation, unless it is nil.
+func (cfg *Config) detectFileType(fullPath string, fInfo os.FileInfo, explanation *Explanation) FileTypeInfo {
+	// Read file contents if it's small enough
+	// (files that report a size of 0, like the ones in /proc, may block when read)
+	if fInfo.Mode().IsRegular() && fInfo.Size() >= cfg.readFileSizeThreshold {
+		// Only read the first block of large files, and stream through the rest to count the lines
+		explanation.Add("contents", "the file is larger than the read threshold (%s), so only the first %s are read", cfg.readThreshold, humanize.IBytes(largeFileBlockSize))
+		return DetectLargeFileType(fullPath, fInfo, cfg.lineCountThreshold, cfg.lineCountTimeout, explanation)
+	}
+	var fileContents []byte
+	if fInfo.Mode().IsRegular() && fInfo.Size() == 0 {
+		fileContents = []byte{}
+	} else if fInfo.Mode().IsRegular() {
+		if data, err := os.ReadFile(fullPath); err == nil {
+			fileContents = data
 		}
-		// Detect file type using contents if available
-		typeInfo := DetectFileType(fn, fInfo, fileContents)
-		// Generate size description
-		var sizeDescription string
-		if typeInfo.IsBinary || typeInfo.LineCount < 0 {
-			sizeDescription = humanize.IBytes(uint64(fInfo.Size()))
-		} else {
-			sizeDescription = fmt.Sprintf("%d lines", typeInfo.LineCount)
+	}
+	// Detect file type using contents if available
+	return detectFileType(fullPath, fInfo, fileContents, explanation)
+}
+
+// analyzeFile detects the file type of a single file and stores the result in the findings
+func (cfg *Config) analyzeFile(fn string, fInfo os.FileInfo, findings *Findings) {
+	// The paths in the findings are relative to the examined directory
+	typeInfo := cfg.detectFileType(filepath.Join(cfg.path, fn), fInfo, nil)
+	// Check if Go binaries were built from an older commit in the examined repository
+	if goBuild := typeInfo.GoBuild; goBuild != nil && goBuild.Revision != "" && findings.git != nil {
+		goBuild.Stale, _ = IsOlderCommit(cfg.path, goBuild.Revision)
+	}
+	// Store directories and files separately
+	if typeInfo.Mode == mode.Blank && fInfo.IsDir() {
+		if fn == "." {
+			return
 		}
-		// Format and print the output
-		if typeInfo.Mode == mode.Blank && fInfo.IsDir() {
-			if fn != "." {
-				findings.dirList = append(findings.dirList, fn)
-			}
-		} else {
-			modified := fInfo.ModTime()
-			cell1 := fmt.Sprintf("<%s>%s</%s>", typeInfo.NameColor, fn, typeInfo.NameColor)
-			cell2 := fmt.Sprintf("[<%s>%s</%s>]", typeInfo.TypeColor, typeInfo.Description, typeInfo.TypeColor)
-			cell3 := TimeString(ok, modified, "lightyellow", "lightblue", "white")
-			cell4 := sizeDescription
-			findings.printMap[modified] = cell1 + ";" + cell2 + ";" + cell3 + ";" + cell4
+		if findings.ndjson != nil {
+			findings.ndjson.WriteDirectory(fn, fInfo)
+			return
+		}
+		findings.mut.Lock()
+		findings.dirList = append(findings.dirList, fn)
+		findings.mut.Unlock()
+	} else {
+		row := FileRow{Path: fn, Info: fInfo, Type: typeInfo}
+		findings.mut.Lock()
+		if findings.ndjson == nil {
+			findings.rows = append(findings.rows, row)
+		}
+		if findings.ndjson == nil || cfg.ollama {
 			findings.fileList = append(findings.fileList, fn)
 		}
+		findings.mut.Unlock()
+		if findings.ndjson != nil {
+			findings.ndjson.WriteFile(&row)
+		}
 	}
-	return nil
 }
 
 func (cfg *Config) ListFiles(ob *strings.Builder, findings *Findings, needsSeparator *bool) error {
 	// List files, if any
-	if l := len(findings.printMap); l > 0 {
+	if len(findings.rows) > 0 {
 		if *needsSeparator {
 			ob.WriteString("\n")
 			*needsSeparator = false
 		}
-		keys := make([]time.Time, l, l)
-		counter := 0
-		for k := range findings.printMap {
-			keys[counter] = k
-			counter++
-		}
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].Before(keys[j])
-		})
-		// Print out the full line of info for a single file, from the printMap
-		for _, k := range keys {
-			ob.WriteString(findings.printMap[k])
-			ob.WriteString("\n")
+		SortRows(findings.rows, cfg.sortKey, cfg.reverse)
+		table := Table{
+			Rows:         make([][]string, len(findings.rows)),
+			RightAlign:   []bool{false, false, false, true}, // right-align the size column
+			MaxWidth:     cfg.termWidth,
+			ShrinkColumn: 0, // ellipsize the file names, if needed
+			Colors:       cfg.colors,
+		}
+		for i := range findings.rows {
+			table.Rows[i] = findings.rows[i].Cells()
 		}
+		ob.WriteString(table.Render())
 		*needsSeparator = true
 	}
 	return nil
@@ -242,6 +456,31 @@ func (cfg *Config) IgnoredFiles(ob *strings.Builder, findings *Findings, needsSe
 	}
 }
 
+func (cfg *Config) WalkErrors(ob *strings.Builder, findings *Findings, needsSeparator *bool) {
+	// Entries that could not be read
+	if errorsLen := len(findings.walkErrors); errorsLen > 0 {
+		if *needsSeparator {
+			ob.WriteString("\n")
+			*needsSeparator = false
+		}
+
+		ob.WriteString(fmt.Sprintf("<red>%d %s could not be read.</red>", errorsLen, english.PluralWord(errorsLen, "entry", "entries")))
+		if !cfg.verbose {
+			ob.WriteString(" <gray>Use --verbose for details.</gray>\n")
+		} else {
+			ob.WriteString("\n")
+			sort.Slice(findings.walkErrors, func(i, j int) bool {
+				return findings.walkErrors[i].Path < findings.walkErrors[j].Path
+			})
+			for _, walkError := range findings.walkErrors {
+				ob.WriteString(fmt.Sprintf("<lightred>%s</lightred>: <white>%s</white>\n", walkError.Path, walkError.Kind))
+			}
+		}
+
+		*needsSeparator = true
+	}
+}
+
 func (cfg *Config) LatestGitCommitThisYear(ob *strings.Builder, findings *Findings, needsSeparator *bool) error {
 	// Git URL
 	if findings.git != nil {
@@ -252,25 +491,14 @@ func (cfg *Config) LatestGitCommitThisYear(ob *strings.Builder, findings *Findin
 
 		ob.WriteString(fmt.Sprintf("<yellow>Git URL:</yellow> <lightblue>%s</lightblue>\n", findings.git.URL))
 
-		r, err := git.PlainOpen(cfg.path)
-		if err != nil {
-			return err
-		}
-		ref, err := r.Head()
-		if err != nil {
-			return err
-		}
-
-		now := time.Now() // time.Date(2025, 1, 27, 0, 0, 0, 0, time.UTC)
 		oneYearAgo := time.Now().AddDate(-1, 0, 0)
 
-		cIter, err := r.Log(&git.LogOptions{From: ref.Hash(), Since: &oneYearAgo, Until: &now})
+		c, err := LatestCommit(cfg.path, oneYearAgo)
 		if err != nil {
 			return err
 		}
 
-		// ignore err here because we want to break the loop early
-		_ = cIter.ForEach(func(c *object.Commit) error {
+		if c != nil {
 			logEntryAsString := strings.TrimRightFunc(c.String(), unicode.IsSpace)
 			if len(logEntryAsString) > 0 {
 				if *needsSeparator {
@@ -283,8 +511,7 @@ func (cfg *Config) LatestGitCommitThisYear(ob *strings.Builder, findings *Findin
 
 				*needsSeparator = true
 			}
-			return errors.New("break") // return nil instead to continue priting git commit messages
-		})
+		}
 	}
 
 	return nil
@@ -318,29 +545,119 @@ func run(cfg *Config) error {
 		ob             strings.Builder // output string
 	)
 
-	findings, err := Examine(cfg.path, cfg.respectIgnored, cfg.respectHidden, cfg.maxDepth)
-	if err != nil {
+	if cfg.explain != "" {
+		if err := cfg.Explain(&ob, cfg.explain); err != nil {
+			return err
+		}
+		cfg.print(ob.String())
+		return nil
+	}
+
+	// Stop walking and analyzing files when ctrl-c is pressed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	findings := NewFindings()
+	if cfg.ndjsonOutput {
+		findings.ndjson = NewNDJSONWriter(os.Stdout)
+	}
+	// The git repository is needed for checking if Go binaries are stale
+	findings.FindGit(cfg.path)
+
+	entries := make(chan Entry, entryBufferSize)
+	walkErr := make(chan error, 1)
+	go func() {
+		walkErr <- ExamineStream(ctx, cfg.path, cfg.examineOptions(), entries)
+	}()
+
+	// Analyze the files while they are being found
+	cfg.AnalyzeFiles(entries, findings)
+
+	if err := <-walkErr; errors.Is(err, context.Canceled) {
+		findings.interrupted = true
+	} else if errors.Is(err, ErrTruncated) {
+		findings.truncated = true
+	} else if err != nil {
 		return fmt.Errorf("file search failed: %v", err)
 	}
-	if err := cfg.AnalyzeFiles(&ob, findings, &needsSeparator); err != nil {
-		return fmt.Errorf("analyzing files failed: %v", err)
+	stop() // a second ctrl-c should terminate pal right away
+
+	if cfg.csvOutput {
+		return cfg.WriteCSV(os.Stdout, findings, ',')
+	} else if cfg.tsvOutput {
+		return cfg.WriteCSV(os.Stdout, findings, '\t')
+	}
+
+	if cfg.jsonOutput || cfg.ndjsonOutput {
+		var buildSuggestion []string
+		if cfg.ollama {
+			if model, err := NewModel(); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not connect to Ollama: %v\n", err)
+			} else if buildCommands, err := model.BuildCommands(strings.Join(findings.fileList, "\n")); err == nil { // success
+				buildSuggestion = buildCommands
+			}
+		}
+		if cfg.ndjsonOutput {
+			findings.ndjson.WriteSummary(cfg, findings, buildSuggestion)
+			return nil
+		}
+		return WriteJSON(os.Stdout, cfg.NewJSONDocument(findings, buildSuggestion))
+	}
+
+	if findings.interrupted {
+		ob.WriteString("<yellow>Interrupted, the listing is incomplete.</yellow>\n")
+		needsSeparator = true
+	} else if findings.truncated {
+		ob.WriteString(fmt.Sprintf("<yellow>Truncated, only the first %d %s are listed.</yellow>\n", cfg.maxEntries, english.PluralWord(cfg.maxEntries, "entry", "entries")))
+		needsSeparator = true
 	}
 
 	cfg.IgnoredFiles(&ob, findings, &needsSeparator)
 
-	cfg.ListDirs(&ob, findings, &needsSeparator)
+	cfg.WalkErrors(&ob, findings, &needsSeparator)
+
+	if cfg.tree {
+		cfg.ListTree(&ob, findings, &needsSeparator)
+	} else {
+		cfg.ListDirs(&ob, findings, &needsSeparator)
+
+		cfg.ListFiles(&ob, findings, &needsSeparator)
+	}
+
+	if cfg.archiveList {
+		cfg.ListArchives(&ob, findings, &needsSeparator)
+	}
+
+	if cfg.stats {
+		cfg.ListStats(&ob, findings, &needsSeparator)
+	}
+
+	if cfg.verbose {
+		cfg.ELFLibraries(&ob, findings, &needsSeparator)
+	}
 
-	cfg.ListFiles(&ob, findings, &needsSeparator)
+	cfg.GoBinaries(&ob, findings, &needsSeparator)
 
 	cfg.LatestGitCommitThisYear(&ob, findings, &needsSeparator)
 
 	cfg.OllamaBuildCommand(&ob, findings, &needsSeparator)
 
-	textoutput.New().Print(ob.String())
+	cfg.print(ob.String())
 
 	return nil
 }
 
+// print writes the given text with color tags to stdout, with or without colors
+func (cfg *Config) print(s string) {
+	o := textoutput.New()
+	if cfg.colors {
+		o.EnableColors() // also when NO_COLOR is set, if --color=always is given
+	} else {
+		o.DisableColors()
+	}
+	o.Print(s)
+}
+
 func main() {
 	if err := NewRootCommand().Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "\nerror: %v\n", err)
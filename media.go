@@ -0,0 +1,798 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+const (
+	maxMediaBoxSize    = 1024 * 1024 // larger MP4 boxes and Matroska elements are not read into memory
+	maxMediaElements   = 4096        // the maximum number of Matroska elements or RIFF chunks that are visited
+	mediaTailBlockSize = 64 * 1024   // the last part of Ogg files, where the last page is searched for
+	maxMediaDepth      = 8           // the maximum nesting of MP4 boxes, Matroska elements and RIFF lists
+)
+
+// errNotMedia is returned by InspectMedia for formats that are not supported, or if no stream information is found
+var errNotMedia = errors.New("no media stream information found")
+
+// mediaContainers are the names of the supported container formats, by the format from the signature database
+var mediaContainers = map[string]string{
+	"MP4":       "MP4",
+	"QuickTime": "QuickTime",
+	"M4A":       "MPEG-4",
+	"Matroska":  "Matroska",
+	"WebM":      "WebM",
+	"Ogg":       "Ogg",
+	"FLAC":      "FLAC",
+	"MP3":       "MP3",
+	"WAV":       "WAV",
+	"AVI":       "AVI",
+}
+
+// MediaInfo contains the duration and stream information of a video or audio file
+type MediaInfo struct {
+	Container  string  `json:"container"` // like "MP4" or "Ogg Vorbis"
+	Kind       string  `json:"kind"`      // "video" or "audio"
+	Duration   float64 `json:"duration"`  // in seconds, or 0 if not known
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	VideoCodec string  `json:"video_codec,omitempty"`
+	AudioCodec string  `json:"audio_codec,omitempty"`
+	SampleRate int     `json:"sample_rate,omitempty"`
+	Channels   int     `json:"channels,omitempty"`
+}
+
+// mediaCodecNames are short names for the codec identifiers in MP4 and Matroska files
+var mediaCodecNames = map[string]string{
+	"avc1": "H.264", "avc3": "H.264", "V_MPEG4/ISO/AVC": "H.264",
+	"hev1": "H.265", "hvc1": "H.265", "V_MPEGH/ISO/HEVC": "H.265",
+	"av01": "AV1", "V_AV1": "AV1",
+	"vp08": "VP8", "V_VP8": "VP8",
+	"vp09": "VP9", "V_VP9": "VP9",
+	"mp4v": "MPEG-4 Visual", "V_MPEG4/ISO/ASP": "MPEG-4 Visual",
+	"jpeg": "Motion JPEG", "V_MJPEG": "Motion JPEG",
+	"apch": "ProRes", "apcn": "ProRes", "apcs": "ProRes", "apco": "ProRes", "ap4h": "ProRes",
+	"mp4a": "AAC", "A_AAC": "AAC",
+	"Opus": "Opus", "A_OPUS": "Opus",
+	"A_VORBIS": "Vorbis",
+	"fLaC":     "FLAC", "A_FLAC": "FLAC",
+	"alac": "ALAC", "A_ALAC": "ALAC",
+	"ac-3": "AC-3", "A_AC3": "AC-3",
+	"ec-3": "E-AC-3", "A_EAC3": "E-AC-3",
+	".mp3": "MP3", "A_MPEG/L3": "MP3",
+	"lpcm": "PCM", "sowt": "PCM", "twos": "PCM", "A_PCM/INT/LIT": "PCM", "A_PCM/INT/BIG": "PCM",
+}
+
+// codecName returns a short name for the given codec identifier, or the identifier itself
+func codecName(id string) string {
+	if name, ok := mediaCodecNames[id]; ok {
+		return name
+	}
+	return strings.TrimSpace(id)
+}
+
+// InspectMedia reads the duration and stream information from the headers of
+// the given video or audio file. The format is the format from the signature database.
+func InspectMedia(filename, format string) (*MediaInfo, error) {
+	container, ok := mediaContainers[format]
+	if !ok {
+		return nil, errNotMedia
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fileInfo.Size()
+
+	info := &MediaInfo{Container: container, Kind: "audio"}
+	switch format {
+	case "MP4", "QuickTime", "M4A":
+		err = readMP4(f, size, info)
+	case "Matroska", "WebM":
+		err = readMatroska(f, size, info)
+	case "Ogg":
+		err = readOgg(f, size, info)
+	case "FLAC":
+		err = readFLAC(f, info)
+	case "MP3":
+		err = readMP3(f, size, info)
+	case "WAV", "AVI":
+		err = readRIFF(f, size, info)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.VideoCodec != "" || info.Width > 0 {
+		info.Kind = "video"
+	}
+	if info.Duration == 0 && info.Width == 0 && info.SampleRate == 0 {
+		return nil, errNotMedia
+	}
+	return info, nil
+}
+
+// formatDuration formats a duration in seconds as "01:23:45"
+func formatDuration(seconds float64) string {
+	total := int64(math.Round(seconds))
+	return fmt.Sprintf("%02d:%02d:%02d", total/3600, total/60%60, total%60)
+}
+
+// channelsName returns "mono", "stereo" or the number of channels
+func channelsName(channels int) string {
+	switch channels {
+	case 1:
+		return "mono"
+	case 2:
+		return "stereo"
+	}
+	return fmt.Sprintf("%d channels", channels)
+}
+
+// Summary returns a short description, like "MP4 video, 1920x1080, 01:23:45" or "FLAC audio, 44.1 kHz, stereo, 00:03:25"
+func (info *MediaInfo) Summary() string {
+	fields := []string{info.Container + " " + info.Kind}
+	if info.Width > 0 && info.Height > 0 {
+		fields = append(fields, fmt.Sprintf("%dx%d", info.Width, info.Height))
+	} else if info.Kind == "audio" && info.SampleRate > 0 {
+		fields = append(fields, fmt.Sprintf("%g kHz", float64(info.SampleRate)/1000))
+		if info.Channels > 0 {
+			fields = append(fields, channelsName(info.Channels))
+		}
+	}
+	if info.Duration > 0 {
+		fields = append(fields, formatDuration(info.Duration))
+	}
+	return strings.Join(fields, ", ")
+}
+
+// readAt reads up to n bytes at the given offset. Returns fewer bytes at the end of the file.
+func readAt(r io.ReaderAt, offset int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+// walkMP4Boxes calls visit for each box between start and end, with the box
+// type and the start and end of the box contents
+func walkMP4Boxes(r io.ReaderAt, start, end int64, visit func(boxType string, dataStart, dataEnd int64) error) error {
+	for offset := start; offset+8 <= end; {
+		header, err := readAt(r, offset, 16)
+		if err != nil {
+			return err
+		}
+		if len(header) < 8 {
+			return nil
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header))
+		boxType := string(header[4:8])
+		dataStart := offset + 8
+		switch boxSize {
+		case 0: // the box extends to the end
+			boxSize = end - offset
+		case 1: // a 64-bit size follows the type
+			if len(header) < 16 {
+				return nil
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:]))
+			dataStart += 8
+		}
+		if boxSize < dataStart-offset || boxSize > end-offset {
+			return nil // truncated or corrupt
+		}
+		if err := visit(boxType, dataStart, offset+boxSize); err != nil {
+			return err
+		}
+		offset += boxSize
+	}
+	return nil
+}
+
+// readMP4 reads the duration and the first video and audio track of an ISO base media file
+func readMP4(r io.ReaderAt, size int64, info *MediaInfo) error {
+	// readBox reads the contents of a box, if it is small enough
+	readBox := func(dataStart, dataEnd int64) ([]byte, error) {
+		if dataEnd-dataStart > maxMediaBoxSize || dataEnd < dataStart {
+			return nil, nil
+		}
+		return readAt(r, dataStart, int(dataEnd-dataStart))
+	}
+	var depth int // the nesting of the boxes within a track
+	var visitTrack func(boxType string, dataStart, dataEnd int64) error
+	var (
+		handler       string // "vide" or "soun", for the current track
+		width, height int
+		codec         string
+		channels      int
+		sampleRate    int
+	)
+	visitTrack = func(boxType string, dataStart, dataEnd int64) error {
+		switch boxType {
+		case "mdia", "minf", "stbl":
+			if depth >= maxMediaDepth {
+				return nil
+			}
+			depth++
+			defer func() { depth-- }()
+			return walkMP4Boxes(r, dataStart, dataEnd, visitTrack)
+		case "tkhd":
+			data, err := readBox(dataStart, dataEnd)
+			if err != nil {
+				return err
+			}
+			// The width and height are 16.16 fixed point numbers at the end of the box
+			if len(data) >= 84 {
+				width = int(binary.BigEndian.Uint32(data[len(data)-8:]) >> 16)
+				height = int(binary.BigEndian.Uint32(data[len(data)-4:]) >> 16)
+			}
+		case "hdlr":
+			data, err := readBox(dataStart, dataEnd)
+			if err != nil {
+				return err
+			}
+			if len(data) >= 12 {
+				handler = string(data[8:12])
+			}
+		case "stsd":
+			data, err := readBox(dataStart, dataEnd)
+			if err != nil {
+				return err
+			}
+			// The first sample entry, after the version, flags and entry count
+			if len(data) >= 16 {
+				entry := data[8:]
+				codec = string(entry[4:8])
+				if len(entry) >= 36 {
+					channels = int(binary.BigEndian.Uint16(entry[24:]))
+					sampleRate = int(binary.BigEndian.Uint32(entry[32:]) >> 16)
+				}
+			}
+		}
+		return nil
+	}
+	return walkMP4Boxes(r, 0, size, func(boxType string, dataStart, dataEnd int64) error {
+		if boxType != "moov" {
+			return nil
+		}
+		return walkMP4Boxes(r, dataStart, dataEnd, func(boxType string, dataStart, dataEnd int64) error {
+			switch boxType {
+			case "mvhd":
+				data, err := readBox(dataStart, dataEnd)
+				if err != nil {
+					return err
+				}
+				if len(data) >= 20 && data[0] == 0 {
+					timescale := binary.BigEndian.Uint32(data[12:])
+					duration := binary.BigEndian.Uint32(data[16:])
+					if timescale > 0 && duration != math.MaxUint32 {
+						info.Duration = float64(duration) / float64(timescale)
+					}
+				} else if len(data) >= 32 && data[0] == 1 {
+					timescale := binary.BigEndian.Uint32(data[20:])
+					duration := binary.BigEndian.Uint64(data[24:])
+					if timescale > 0 && duration != math.MaxUint64 {
+						info.Duration = float64(duration) / float64(timescale)
+					}
+				}
+			case "trak":
+				handler, width, height, codec, channels, sampleRate = "", 0, 0, "", 0, 0
+				if err := walkMP4Boxes(r, dataStart, dataEnd, visitTrack); err != nil {
+					return err
+				}
+				switch {
+				case handler == "vide" && info.VideoCodec == "":
+					info.VideoCodec = codecName(codec)
+					info.Width, info.Height = width, height
+				case handler == "soun" && info.AudioCodec == "":
+					info.AudioCodec = codecName(codec)
+					info.Channels, info.SampleRate = channels, sampleRate
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Matroska element IDs
+const (
+	ebmlSegment           = 0x18538067
+	ebmlInfo              = 0x1549A966
+	ebmlTimestampScale    = 0x2AD7B1
+	ebmlDuration          = 0x4489
+	ebmlTracks            = 0x1654AE6B
+	ebmlTrackEntry        = 0xAE
+	ebmlTrackType         = 0x83
+	ebmlCodecID           = 0x86
+	ebmlVideo             = 0xE0
+	ebmlPixelWidth        = 0xB0
+	ebmlPixelHeight       = 0xBA
+	ebmlAudio             = 0xE1
+	ebmlSamplingFrequency = 0xB5
+	ebmlChannels          = 0x9F
+	ebmlCluster           = 0x1F43B675
+)
+
+// readEBMLHeader reads the ID and size of the EBML element at the given
+// offset. The size is -1 if it is unknown.
+func readEBMLHeader(r io.ReaderAt, offset int64) (id uint32, size int64, headerLength int, err error) {
+	buf, err := readAt(r, offset, 12)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if len(buf) < 2 {
+		return 0, 0, 0, io.ErrUnexpectedEOF
+	}
+	// The ID keeps its length marker, and is 1 to 4 bytes long
+	idLength := 1
+	for idLength <= 4 && buf[0]&(0x80>>(idLength-1)) == 0 {
+		idLength++
+	}
+	if idLength > 4 || len(buf) < idLength+1 {
+		return 0, 0, 0, errNotMedia
+	}
+	for _, b := range buf[:idLength] {
+		id = id<<8 | uint32(b)
+	}
+	// The size is 1 to 8 bytes long, without the length marker
+	rest := buf[idLength:]
+	sizeLength := 1
+	for sizeLength <= 8 && rest[0]&(0x80>>(sizeLength-1)) == 0 {
+		sizeLength++
+	}
+	if sizeLength > 8 || len(rest) < sizeLength {
+		return 0, 0, 0, errNotMedia
+	}
+	value := uint64(rest[0] & (0xff >> sizeLength))
+	allOnes := value == uint64(0xff>>sizeLength)
+	for _, b := range rest[1:sizeLength] {
+		value = value<<8 | uint64(b)
+		allOnes = allOnes && b == 0xff
+	}
+	if allOnes {
+		return id, -1, idLength + sizeLength, nil
+	}
+	return id, int64(value), idLength + sizeLength, nil
+}
+
+// walkEBML calls visit for each element between start and end, with the
+// element ID and the start and end of the element contents. Elements of
+// unknown size extend to the end. Stops if visit returns false.
+func walkEBML(r io.ReaderAt, start, end int64, visit func(id uint32, dataStart, dataEnd int64) (bool, error)) error {
+	for offset, count := start, 0; offset < end && count < maxMediaElements; count++ {
+		id, size, headerLength, err := readEBMLHeader(r, offset)
+		if err != nil {
+			return nil // truncated or corrupt
+		}
+		dataStart := offset + int64(headerLength)
+		if dataStart > end {
+			return nil // truncated
+		}
+		dataEnd := dataStart + size
+		if size < 0 || dataEnd > end {
+			dataEnd = end
+		}
+		if more, err := visit(id, dataStart, dataEnd); err != nil || !more {
+			return err
+		}
+		offset = dataEnd
+	}
+	return nil
+}
+
+// ebmlUint decodes an unsigned integer element
+func ebmlUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+// ebmlFloat decodes a 4 or 8 byte floating point element
+func ebmlFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
+
+// readMatroska reads the duration and the first video and audio track of a Matroska or WebM file
+func readMatroska(r io.ReaderAt, size int64, info *MediaInfo) error {
+	// readElement reads the contents of an element, if it is small enough
+	readElement := func(dataStart, dataEnd int64) ([]byte, error) {
+		if dataEnd-dataStart > maxMediaBoxSize || dataEnd < dataStart {
+			return nil, nil
+		}
+		return readAt(r, dataStart, int(dataEnd-dataStart))
+	}
+	var (
+		timestampScale = 1000000.0 // nanoseconds
+		duration       float64
+		foundInfo      bool
+		foundTracks    bool
+	)
+	visitTrackEntry := func(dataStart, dataEnd int64) error {
+		var (
+			trackType       uint64
+			codecID         string
+			width, height   int
+			sampleRate      float64
+			channels        int
+			depth           int // the nesting of the Video and Audio elements
+			visitAudioVideo func(id uint32, dataStart, dataEnd int64) (bool, error)
+		)
+		visitAudioVideo = func(id uint32, dataStart, dataEnd int64) (bool, error) {
+			switch id {
+			case ebmlVideo, ebmlAudio:
+				if depth >= maxMediaDepth {
+					return true, nil
+				}
+				depth++
+				defer func() { depth-- }()
+				return true, walkEBML(r, dataStart, dataEnd, visitAudioVideo)
+			case ebmlTrackType, ebmlCodecID, ebmlPixelWidth, ebmlPixelHeight, ebmlSamplingFrequency, ebmlChannels:
+				data, err := readElement(dataStart, dataEnd)
+				if err != nil {
+					return false, err
+				}
+				switch id {
+				case ebmlTrackType:
+					trackType = ebmlUint(data)
+				case ebmlCodecID:
+					codecID = string(bytes.TrimRight(data, "\x00"))
+				case ebmlPixelWidth:
+					width = int(ebmlUint(data))
+				case ebmlPixelHeight:
+					height = int(ebmlUint(data))
+				case ebmlSamplingFrequency:
+					sampleRate = ebmlFloat(data)
+				case ebmlChannels:
+					channels = int(ebmlUint(data))
+				}
+			}
+			return true, nil
+		}
+		if err := walkEBML(r, dataStart, dataEnd, visitAudioVideo); err != nil {
+			return err
+		}
+		switch {
+		case trackType == 1 && info.VideoCodec == "":
+			info.VideoCodec = codecName(codecID)
+			info.Width, info.Height = width, height
+		case trackType == 2 && info.AudioCodec == "":
+			info.AudioCodec = codecName(codecID)
+			info.SampleRate, info.Channels = int(sampleRate), channels
+			if info.Channels == 0 {
+				info.Channels = 1 // the default in the Matroska specification
+			}
+		}
+		return nil
+	}
+	err := walkEBML(r, 0, size, func(id uint32, dataStart, dataEnd int64) (bool, error) {
+		if id != ebmlSegment {
+			return true, nil // like the EBML header
+		}
+		err := walkEBML(r, dataStart, dataEnd, func(id uint32, dataStart, dataEnd int64) (bool, error) {
+			switch id {
+			case ebmlInfo:
+				foundInfo = true
+				err := walkEBML(r, dataStart, dataEnd, func(id uint32, dataStart, dataEnd int64) (bool, error) {
+					if id != ebmlTimestampScale && id != ebmlDuration {
+						return true, nil
+					}
+					data, err := readElement(dataStart, dataEnd)
+					if err != nil {
+						return false, err
+					}
+					if id == ebmlTimestampScale {
+						timestampScale = float64(ebmlUint(data))
+					} else {
+						duration = ebmlFloat(data)
+					}
+					return true, nil
+				})
+				return err == nil, err
+			case ebmlTracks:
+				foundTracks = true
+				err := walkEBML(r, dataStart, dataEnd, func(id uint32, dataStart, dataEnd int64) (bool, error) {
+					if id == ebmlTrackEntry {
+						return true, visitTrackEntry(dataStart, dataEnd)
+					}
+					return true, nil
+				})
+				return err == nil, err
+			case ebmlCluster:
+				// The media data comes after the headers, in most files
+				return !(foundInfo && foundTracks), nil
+			}
+			return true, nil
+		})
+		return false, err
+	})
+	info.Duration = duration * timestampScale / 1e9
+	return err
+}
+
+// readOgg reads the codec from the first page of an Ogg file, and the duration from the last page
+func readOgg(r io.ReaderAt, size int64, info *MediaInfo) error {
+	first, err := readAt(r, 0, 512)
+	if err != nil {
+		return err
+	}
+	// The page header is followed by a table with the size of each segment
+	if len(first) < 27 || len(first) < 27+int(first[26]) {
+		return errNotMedia
+	}
+	serial := binary.LittleEndian.Uint32(first[14:])
+	packet := first[27+int(first[26]):]
+	var (
+		granuleRate  int    // the number of granules per second, or 0 if the duration can not be found
+		granuleStart uint64 // the number of granules to subtract, like the Opus pre-skip
+	)
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")) && len(packet) >= 16:
+		info.Container, info.AudioCodec = "Ogg Vorbis", "Vorbis"
+		info.Channels = int(packet[11])
+		info.SampleRate = int(binary.LittleEndian.Uint32(packet[12:]))
+		granuleRate = info.SampleRate
+	case bytes.HasPrefix(packet, []byte("OpusHead")) && len(packet) >= 16:
+		info.Container, info.AudioCodec = "Ogg Opus", "Opus"
+		info.Channels = int(packet[9])
+		info.SampleRate = int(binary.LittleEndian.Uint32(packet[12:]))
+		granuleRate = 48000 // Opus granules are always at 48 kHz
+		granuleStart = uint64(binary.LittleEndian.Uint16(packet[10:]))
+	case bytes.HasPrefix(packet, []byte("\x7fFLAC")) && len(packet) >= 13+4+18 && string(packet[9:13]) == "fLaC":
+		info.Container, info.AudioCodec = "Ogg FLAC", "FLAC"
+		sampleRate, channels, _ := parseFLACStreamInfo(packet[17:])
+		info.SampleRate, info.Channels = sampleRate, channels
+		granuleRate = sampleRate
+	case bytes.HasPrefix(packet, []byte("\x80theora")) && len(packet) >= 20:
+		info.Container, info.VideoCodec = "Ogg Theora", "Theora"
+		info.Width = int(packet[14])<<16 | int(packet[15])<<8 | int(packet[16])
+		info.Height = int(packet[17])<<16 | int(packet[18])<<8 | int(packet[19])
+	default:
+		return errNotMedia
+	}
+	if granuleRate == 0 {
+		return nil
+	}
+
+	// Find the last page of the same stream, and use the granule position
+	tailStart := max(0, size-mediaTailBlockSize)
+	tail, err := readAt(r, tailStart, int(size-tailStart))
+	if err != nil {
+		return err
+	}
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if i+18 > len(tail) || binary.LittleEndian.Uint32(tail[i+14:]) != serial {
+			continue
+		}
+		granule := binary.LittleEndian.Uint64(tail[i+6:])
+		if granule == math.MaxUint64 { // no packet ends on this page
+			continue
+		}
+		if granule > granuleStart {
+			info.Duration = float64(granule-granuleStart) / float64(granuleRate)
+		}
+		break
+	}
+	return nil
+}
+
+// parseFLACStreamInfo reads the sample rate, number of channels and total number of samples from a STREAMINFO block
+func parseFLACStreamInfo(streamInfo []byte) (sampleRate, channels int, totalSamples uint64) {
+	if len(streamInfo) < 18 {
+		return 0, 0, 0
+	}
+	bits := binary.BigEndian.Uint64(streamInfo[10:])
+	sampleRate = int(bits >> 44)
+	channels = int(bits>>41&0x7) + 1
+	totalSamples = bits & 0xfffffffff
+	return sampleRate, channels, totalSamples
+}
+
+// readFLAC reads the STREAMINFO block, which comes right after the "fLaC" marker
+func readFLAC(r io.ReaderAt, info *MediaInfo) error {
+	data, err := readAt(r, 0, 4+4+34)
+	if err != nil {
+		return err
+	}
+	if len(data) < 42 || data[4]&0x7f != 0 { // the first block must be STREAMINFO
+		return errNotMedia
+	}
+	sampleRate, channels, totalSamples := parseFLACStreamInfo(data[8:])
+	info.AudioCodec, info.SampleRate, info.Channels = "FLAC", sampleRate, channels
+	if sampleRate > 0 {
+		info.Duration = float64(totalSamples) / float64(sampleRate)
+	}
+	return nil
+}
+
+// MPEG audio layer III bit rates in kbit/s and sample rates in Hz, for MPEG-1 and MPEG-2 (and 2.5)
+var (
+	mp3BitRates = [2][16]int{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	}
+	mp3SampleRates = map[byte][3]int{
+		3: {44100, 48000, 32000}, // MPEG-1
+		2: {22050, 24000, 16000}, // MPEG-2
+		0: {11025, 12000, 8000},  // MPEG-2.5
+	}
+)
+
+// readMP3 reads the first frame header of an MP3 file, and the number of
+// frames from the Xing or VBRI header, if there is one. The duration of
+// files without such a header is found by the bit rate of the first frame.
+func readMP3(r io.ReaderAt, size int64, info *MediaInfo) error {
+	data, err := readAt(r, 0, 10)
+	if err != nil {
+		return err
+	}
+	// Skip the ID3v2 tag
+	var start int64
+	if len(data) == 10 && bytes.HasPrefix(data, []byte("ID3")) {
+		start = 10 + (int64(data[6]&0x7f)<<21 | int64(data[7]&0x7f)<<14 | int64(data[8]&0x7f)<<7 | int64(data[9]&0x7f))
+		if data[5]&0x10 != 0 { // there is a footer
+			start += 10
+		}
+	}
+	data, err = readAt(r, start, 4096)
+	if err != nil {
+		return err
+	}
+	// Find the first frame header, which should be right after the tag
+	i := 0
+	for ; i+4 <= len(data); i++ {
+		if data[i] == 0xff && data[i+1]&0xe0 == 0xe0 && data[i+1]>>1&0x3 == 1 && data[i+2]>>4 != 0xf && data[i+2]>>2&0x3 != 3 {
+			break
+		}
+	}
+	if i+4 > len(data) {
+		return errNotMedia
+	}
+	frame := data[i:]
+	version := frame[1] >> 3 & 0x3
+	sampleRates, ok := mp3SampleRates[version]
+	if !ok {
+		return errNotMedia
+	}
+	mpeg1 := version == 3
+	bitRates := mp3BitRates[1]
+	samplesPerFrame := 576
+	sideInfoLength := 17
+	if mpeg1 {
+		bitRates = mp3BitRates[0]
+		samplesPerFrame = 1152
+		sideInfoLength = 32
+	}
+	if frame[3]>>6 == 3 { // mono, which has a shorter side info
+		info.Channels = 1
+		sideInfoLength = 9
+		if mpeg1 {
+			sideInfoLength = 17
+		}
+	} else {
+		info.Channels = 2
+	}
+	info.AudioCodec = "MP3"
+	info.SampleRate = sampleRates[frame[2]>>2&0x3]
+	bitRate := bitRates[frame[2]>>4]
+
+	// Look for a Xing or Info header (VBR or CBR), or a VBRI header, with the number of frames
+	var frames uint32
+	if xing := 4 + sideInfoLength; xing+12 <= len(frame) && (string(frame[xing:xing+4]) == "Xing" || string(frame[xing:xing+4]) == "Info") {
+		if binary.BigEndian.Uint32(frame[xing+4:])&0x1 != 0 {
+			frames = binary.BigEndian.Uint32(frame[xing+8:])
+		}
+	} else if vbri := 4 + 32; vbri+18 <= len(frame) && string(frame[vbri:vbri+4]) == "VBRI" {
+		frames = binary.BigEndian.Uint32(frame[vbri+14:])
+	}
+	switch {
+	case frames > 0:
+		info.Duration = float64(frames) * float64(samplesPerFrame) / float64(info.SampleRate)
+	case bitRate > 0 && size > start+int64(i):
+		info.Duration = float64(size-start-int64(i)) * 8 / float64(bitRate*1000)
+	}
+	return nil
+}
+
+// wavFormats are the names of the most common WAV format tags
+var wavFormats = map[uint16]string{
+	0x0001: "PCM",
+	0x0003: "PCM float",
+	0x0006: "A-law",
+	0x0007: "µ-law",
+	0x0055: "MP3",
+	0xfffe: "PCM", // WAVE_FORMAT_EXTENSIBLE, which is nearly always PCM
+}
+
+// walkRIFFChunks calls visit for each chunk between start and end, with the
+// chunk ID and the start and end of the chunk contents
+func walkRIFFChunks(r io.ReaderAt, start, end int64, visit func(id string, dataStart, dataEnd int64) error) error {
+	for offset, count := start, 0; offset+8 <= end && count < maxMediaElements; count++ {
+		header, err := readAt(r, offset, 8)
+		if err != nil {
+			return err
+		}
+		if len(header) < 8 {
+			return nil
+		}
+		dataStart := offset + 8
+		dataEnd := dataStart + int64(binary.LittleEndian.Uint32(header[4:]))
+		if err := visit(string(header[:4]), dataStart, min(dataEnd, end)); err != nil {
+			return err
+		}
+		offset = dataEnd + dataEnd%2 // chunks are padded to an even size
+	}
+	return nil
+}
+
+// readRIFF reads the format and duration of a WAV file, or the main header and the first video stream of an AVI file
+func readRIFF(r io.ReaderAt, size int64, info *MediaInfo) error {
+	var byteRate uint32
+	var depth int // the nesting of the lists
+	var visit func(id string, dataStart, dataEnd int64) error
+	visit = func(id string, dataStart, dataEnd int64) error {
+		if id == "LIST" {
+			if depth >= maxMediaDepth {
+				return nil
+			}
+			// Lists, like "hdrl" and "strl" in AVI files, contain more chunks after the list type
+			depth++
+			defer func() { depth-- }()
+			return walkRIFFChunks(r, dataStart+4, dataEnd, visit)
+		}
+		if id == "data" {
+			if byteRate > 0 {
+				info.Duration = float64(dataEnd-dataStart) / float64(byteRate)
+			}
+			return nil
+		}
+		if id != "fmt " && id != "avih" && id != "strh" {
+			return nil
+		}
+		data, err := readAt(r, dataStart, int(min(dataEnd-dataStart, 64)))
+		if err != nil {
+			return err
+		}
+		switch {
+		case id == "fmt " && len(data) >= 12:
+			formatTag := binary.LittleEndian.Uint16(data)
+			if name, ok := wavFormats[formatTag]; ok {
+				info.AudioCodec = name
+			} else {
+				info.AudioCodec = fmt.Sprintf("format 0x%04x", formatTag)
+			}
+			info.Channels = int(binary.LittleEndian.Uint16(data[2:]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(data[4:]))
+			byteRate = binary.LittleEndian.Uint32(data[8:])
+		case id == "avih" && len(data) >= 40:
+			microSecondsPerFrame := binary.LittleEndian.Uint32(data)
+			totalFrames := binary.LittleEndian.Uint32(data[16:])
+			info.Duration = float64(totalFrames) * float64(microSecondsPerFrame) / 1e6
+			info.Width = int(binary.LittleEndian.Uint32(data[32:]))
+			info.Height = int(binary.LittleEndian.Uint32(data[36:]))
+		case id == "strh" && len(data) >= 8 && string(data[:4]) == "vids" && info.VideoCodec == "":
+			info.VideoCodec = strings.TrimRight(string(data[4:8]), "\x00 ")
+		}
+		return nil
+	}
+	return walkRIFFChunks(r, 12, size, visit)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+// join concatenates the given byte slices
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// be32 and le32 encode a 32-bit number
+func be32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func le32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+// mp4Box returns an MP4 box with the given type and contents
+func mp4Box(boxType string, parts ...[]byte) []byte {
+	data := join(parts...)
+	return join(be32(uint32(8+len(data))), []byte(boxType), data)
+}
+
+// ebmlElement returns a Matroska element with the given ID and contents, and an 8-byte size
+func ebmlElement(id []byte, parts ...[]byte) []byte {
+	data := join(parts...)
+	size := binary.BigEndian.AppendUint64(nil, uint64(len(data)))
+	size[0] = 0x01
+	return join(id, size, data)
+}
+
+// riffChunk returns a RIFF chunk with the given ID and contents
+func riffChunk(id string, parts ...[]byte) []byte {
+	data := join(parts...)
+	return join([]byte(id), le32(uint32(len(data))), data)
+}
+
+// oggPage returns an Ogg page with a single packet
+func oggPage(granule uint64, packet []byte) []byte {
+	header := join([]byte("OggS\x00\x02"), binary.LittleEndian.AppendUint64(nil, granule), le32(1), le32(0), le32(0), []byte{1, byte(len(packet))})
+	return join(header, packet)
+}
+
+// mediaReader is one of the container parsers
+type mediaReader func(r io.ReaderAt, size int64, info *MediaInfo) error
+
+// validMediaFiles are small, valid files for each container parser, and the expected summary
+var validMediaFiles = []struct {
+	name      string
+	container string
+	read      mediaReader
+	data      []byte
+	summary   string
+}{
+	{
+		name:      "MP4",
+		container: "MP4",
+		read:      readMP4,
+		data: func() []byte {
+			mvhd := make([]byte, 100)
+			binary.BigEndian.PutUint32(mvhd[12:], 1000)
+			binary.BigEndian.PutUint32(mvhd[16:], 5025000)
+			tkhd := make([]byte, 84)
+			binary.BigEndian.PutUint32(tkhd[76:], 1920<<16)
+			binary.BigEndian.PutUint32(tkhd[80:], 1080<<16)
+			hdlr := join(make([]byte, 8), []byte("vide"), make([]byte, 12))
+			stsd := join(make([]byte, 4), be32(1), be32(86), []byte("avc1"), make([]byte, 78))
+			trak := mp4Box("trak", mp4Box("tkhd", tkhd), mp4Box("mdia", mp4Box("hdlr", hdlr), mp4Box("minf", mp4Box("stbl", mp4Box("stsd", stsd)))))
+			return join(mp4Box("ftyp", []byte("isom"), be32(0)), mp4Box("moov", mp4Box("mvhd", mvhd), trak))
+		}(),
+		summary: "MP4 video, 1920x1080, 01:23:45",
+	},
+	{
+		name:      "Matroska",
+		container: "Matroska",
+		read:      readMatroska,
+		data: func() []byte {
+			duration := binary.BigEndian.AppendUint64(nil, math.Float64bits(125000))
+			segmentInfo := ebmlElement([]byte{0x15, 0x49, 0xa9, 0x66}, ebmlElement([]byte{0x44, 0x89}, duration))
+			video := ebmlElement([]byte{0xe0}, ebmlElement([]byte{0xb0}, []byte{0x05, 0x00}), ebmlElement([]byte{0xba}, []byte{0x02, 0xd0}))
+			track := ebmlElement([]byte{0xae}, ebmlElement([]byte{0x83}, []byte{1}), ebmlElement([]byte{0x86}, []byte("V_VP9")), video)
+			tracks := ebmlElement([]byte{0x16, 0x54, 0xae, 0x6b}, track)
+			return join(ebmlElement([]byte{0x1a, 0x45, 0xdf, 0xa3}), ebmlElement([]byte{0x18, 0x53, 0x80, 0x67}, segmentInfo, tracks))
+		}(),
+		summary: "Matroska video, 1280x720, 00:02:05",
+	},
+	{
+		name:      "Ogg",
+		container: "Ogg",
+		read:      readOgg,
+		data: func() []byte {
+			vorbis := join([]byte("\x01vorbis"), le32(0), []byte{2}, le32(44100), make([]byte, 14))
+			return join(oggPage(0, vorbis), oggPage(44100*200, []byte("x")))
+		}(),
+		summary: "Ogg Vorbis audio, 44.1 kHz, stereo, 00:03:20",
+	},
+	{
+		name:      "FLAC",
+		container: "FLAC",
+		read:      func(r io.ReaderAt, _ int64, info *MediaInfo) error { return readFLAC(r, info) },
+		data: func() []byte {
+			streamInfo := make([]byte, 34)
+			binary.BigEndian.PutUint64(streamInfo[10:], 44100<<44|1<<41|15<<36|44100*205)
+			return join([]byte("fLaC\x80\x00\x00\x22"), streamInfo)
+		}(),
+		summary: "FLAC audio, 44.1 kHz, stereo, 00:03:25",
+	},
+	{
+		name:      "MP3",
+		container: "MP3",
+		read:      readMP3,
+		data: func() []byte {
+			frame := join([]byte{0xff, 0xfb, 0x90, 0x00}, make([]byte, 413))
+			return join([]byte("ID3\x03\x00\x00\x00\x00\x00\x10"), make([]byte, 16), bytes.Repeat(frame, 16000*2/len(frame)+1)[:16000*2])
+		}(),
+		summary: "MP3 audio, 44.1 kHz, stereo, 00:00:02",
+	},
+	{
+		name:      "WAV",
+		container: "WAV",
+		read:      readRIFF,
+		data: func() []byte {
+			format := join([]byte{1, 0, 2, 0}, le32(48000), le32(48000*4), []byte{4, 0, 16, 0})
+			return join([]byte("RIFF"), le32(0), []byte("WAVE"), riffChunk("fmt ", format), riffChunk("data", make([]byte, 48000*4*3)))
+		}(),
+		summary: "WAV audio, 48 kHz, stereo, 00:00:03",
+	},
+	{
+		name:      "AVI",
+		container: "AVI",
+		read:      readRIFF,
+		data: func() []byte {
+			avih := make([]byte, 56)
+			binary.LittleEndian.PutUint32(avih, 40000)
+			binary.LittleEndian.PutUint32(avih[16:], 250)
+			binary.LittleEndian.PutUint32(avih[32:], 640)
+			binary.LittleEndian.PutUint32(avih[36:], 480)
+			strl := riffChunk("LIST", []byte("strl"), riffChunk("strh", []byte("vidsXVID"), make([]byte, 48)))
+			hdrl := riffChunk("LIST", []byte("hdrl"), riffChunk("avih", avih), strl)
+			return join([]byte("RIFF"), le32(0), []byte("AVI "), hdrl)
+		}(),
+		summary: "AVI video, 640x480, 00:00:10",
+	},
+}
+
+func TestMediaReaders(t *testing.T) {
+	for _, tc := range validMediaFiles {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &MediaInfo{Container: tc.container, Kind: "audio"}
+			if err := tc.read(bytes.NewReader(tc.data), int64(len(tc.data)), info); err != nil {
+				t.Fatal(err)
+			}
+			if info.VideoCodec != "" || info.Width > 0 {
+				info.Kind = "video"
+			}
+			if got := info.Summary(); got != tc.summary {
+				t.Errorf("got %q, want %q", got, tc.summary)
+			}
+		})
+	}
+}
+
+// TestMediaReadersTruncated checks that the container parsers do not panic
+// when the files are cut off at any point
+func TestMediaReadersTruncated(t *testing.T) {
+	for _, tc := range validMediaFiles {
+		t.Run(tc.name, func(t *testing.T) {
+			for n := 0; n < len(tc.data) && n < 4096; n++ {
+				var info MediaInfo
+				_ = tc.read(bytes.NewReader(tc.data[:n]), int64(n), &info)
+				if info.Duration < 0 || info.Width < 0 || info.Height < 0 {
+					t.Errorf("truncated at %d bytes: %+v", n, info)
+				}
+			}
+		})
+	}
+}
+
+// TestMediaReadersCorrupt checks that the container parsers do not panic,
+// run out of memory or recurse without bounds on headers with bad sizes
+func TestMediaReadersCorrupt(t *testing.T) {
+	deepList := []byte("x")
+	for range 2000 {
+		deepList = riffChunk("LIST", []byte("junk"), deepList)
+	}
+	deepBox := []byte("x")
+	for range 2000 {
+		deepBox = mp4Box("mdia", deepBox)
+	}
+	for _, tc := range []struct {
+		name string
+		read mediaReader
+		data []byte
+	}{
+		{"Ogg with a short segment table", readOgg, join([]byte("OggS"), make([]byte, 22), []byte{0xff}, make([]byte, 5))},
+		{"Ogg with only a page header", readOgg, join([]byte("OggS"), make([]byte, 23))},
+		{"MP4 with a 64-bit size that overflows", readMP4, mp4Box("moov", be32(1), []byte("mvhd"), binary.BigEndian.AppendUint64(nil, math.MaxInt64), make([]byte, 100))},
+		{"MP4 with a 64-bit size that is negative", readMP4, join(be32(1), []byte("moov"), binary.BigEndian.AppendUint64(nil, math.MaxUint64))},
+		{"MP4 with a box that is larger than the file", readMP4, join(be32(0xffffffff), []byte("moov"), mp4Box("mvhd"))},
+		{"MP4 with a box that is smaller than its header", readMP4, join(be32(4), []byte("moov"), make([]byte, 16))},
+		{"MP4 with deeply nested boxes", readMP4, mp4Box("moov", mp4Box("trak", deepBox))},
+		{"MP4 with empty track boxes", readMP4, mp4Box("moov", mp4Box("mvhd"), mp4Box("trak", mp4Box("tkhd"), mp4Box("mdia", mp4Box("hdlr"), mp4Box("minf", mp4Box("stbl", mp4Box("stsd"))))))},
+		{"Matroska with a header at the end", readMatroska, []byte{0x1a, 0x45, 0xdf, 0xa3, 0x10}},
+		{"Matroska with an element that is larger than the file", readMatroska, join([]byte{0x18, 0x53, 0x80, 0x67, 0x01, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff}, []byte{0x15, 0x49, 0xa9, 0x66, 0x88})},
+		{"Matroska with an invalid ID", readMatroska, make([]byte, 32)},
+		{"Matroska with an invalid size", readMatroska, []byte{0x18, 0x53, 0x80, 0x67, 0x00, 0x00}},
+		{"Matroska with an element header that is larger than its parent", readMatroska, ebmlElement([]byte{0x18, 0x53, 0x80, 0x67}, []byte{0x15, 0x49, 0xa9, 0x66, 0x82, 0x2a, 0xd7, 0xb1, 0x81, 0x01})},
+		{"FLAC with a short STREAMINFO block", func(r io.ReaderAt, _ int64, info *MediaInfo) error { return readFLAC(r, info) }, []byte("fLaC\x00\x00\x00\x22\x00")},
+		{"MP3 with a tag that is larger than the file", readMP3, []byte("ID3\x03\x00\x00\x7f\x7f\x7f\x7f")},
+		{"MP3 with a frame header at the end", readMP3, []byte{0xff, 0xfb, 0x90}},
+		{"MP3 with a frame header and no side info", readMP3, []byte{0xff, 0xfb, 0x90, 0x00, 'X', 'i', 'n', 'g'}},
+		{"RIFF with a chunk that is larger than the file", readRIFF, join([]byte("RIFF\x00\x00\x00\x00WAVE"), []byte("fmt "), le32(math.MaxUint32), []byte{1, 0})},
+		{"RIFF with a short list", readRIFF, join([]byte("RIFF\x00\x00\x00\x00AVI "), []byte("LIST"), le32(2), []byte("hd"))},
+		{"RIFF with deeply nested lists", readRIFF, join([]byte("RIFF\x00\x00\x00\x00AVI "), deepList)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var info MediaInfo
+			_ = tc.read(bytes.NewReader(tc.data), int64(len(tc.data)), &info)
+			if info.Duration < 0 || info.Width < 0 || info.Height < 0 {
+				t.Errorf("got %+v", info)
+			}
+		})
+	}
+}
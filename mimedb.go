@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// mimeTypesPath is the list of MIME types and extensions that comes with most Linux distributions
+const mimeTypesPath = "/etc/mime.types"
+
+// sharedMIMEInfoPaths are the locations of the freedesktop shared-mime-info database
+var sharedMIMEInfoPaths = []string{
+	"/usr/share/mime/packages/freedesktop.org.xml",
+	"/usr/local/share/mime/packages/freedesktop.org.xml",
+}
+
+var (
+	mimeDB     *MIMEDatabase
+	mimeDBOnce sync.Once // DetectFileType is called concurrently
+)
+
+// mimeGlob is a filename pattern from the shared-mime-info database, like "*.png"
+type mimeGlob struct {
+	pattern       string
+	mimeType      string
+	weight        int  // 50 by default, higher is preferred
+	caseSensitive bool // false if the pattern should be matched against the lowercase filename
+}
+
+// magicMatch is a magic rule from the shared-mime-info database. The value
+// may start anywhere from offset to rangeEnd. If there are child rules, one
+// of them must also match.
+type magicMatch struct {
+	offset   int
+	rangeEnd int
+	value    []byte
+	mask     []byte // nil if all bits are compared
+	children []*magicMatch
+}
+
+// mimeMagic is the list of magic rules for one MIME type, where any rule may match
+type mimeMagic struct {
+	mimeType string
+	priority int // 50 by default, higher is preferred
+	matches  []*magicMatch
+}
+
+// MIMEDatabase finds the MIME type of a file by the filename or by the contents
+type MIMEDatabase struct {
+	extensions  map[string]string // from /etc/mime.types, the extension without the dot
+	simpleGlobs map[string]mimeGlob
+	globs       []mimeGlob // the patterns that are not on the form "*.ext"
+	magic       []mimeMagic
+	comments    map[string]string // descriptions of the MIME types, like "PNG image"
+}
+
+// mimeTypes returns the MIME database, which is loaded the first time it is needed
+func mimeTypes() *MIMEDatabase {
+	mimeDBOnce.Do(func() {
+		mimeDB = LoadMIMEDatabase(mimeTypesPath, sharedMIMEInfoPaths)
+	})
+	return mimeDB
+}
+
+// LoadMIMEDatabase reads the given mime.types file and the first
+// shared-mime-info XML file that can be read. Files that are missing or
+// can not be parsed are skipped, since there is also an embedded table.
+func LoadMIMEDatabase(mimeTypesFilename string, sharedMIMEInfoFilenames []string) *MIMEDatabase {
+	db := &MIMEDatabase{
+		extensions:  make(map[string]string),
+		simpleGlobs: make(map[string]mimeGlob),
+		comments:    make(map[string]string),
+	}
+	if data, err := os.ReadFile(mimeTypesFilename); err == nil { // success
+		db.addMIMETypes(data)
+	}
+	for _, filename := range sharedMIMEInfoFilenames {
+		if data, err := os.ReadFile(filename); err == nil && db.addSharedMIMEInfo(data) == nil { // success
+			break
+		}
+	}
+	return db
+}
+
+// addMIMETypes adds the extensions from a mime.types file, where each line
+// is a MIME type followed by a list of extensions
+func (db *MIMEDatabase) addMIMETypes(data []byte) {
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		fields := bytes.Fields(line)
+		if len(fields) < 2 || bytes.HasPrefix(fields[0], []byte("#")) {
+			continue
+		}
+		for _, ext := range fields[1:] {
+			db.extensions[strings.ToLower(string(ext))] = string(fields[0])
+		}
+	}
+}
+
+// sharedMIMEInfo is the structure of the shared-mime-info XML files
+type sharedMIMEInfo struct {
+	MIMETypes []struct {
+		Type     string `xml:"type,attr"`
+		Comments []struct {
+			Lang string `xml:"lang,attr"`
+			Text string `xml:",chardata"`
+		} `xml:"comment"`
+		Globs []struct {
+			Pattern       string `xml:"pattern,attr"`
+			Weight        int    `xml:"weight,attr"`
+			CaseSensitive bool   `xml:"case-sensitive,attr"`
+		} `xml:"glob"`
+		Magic []struct {
+			Priority int               `xml:"priority,attr"`
+			Matches  []sharedMIMEMatch `xml:"match"`
+		} `xml:"magic"`
+	} `xml:"mime-type"`
+}
+
+// sharedMIMEMatch is a magic rule in the shared-mime-info XML files
+type sharedMIMEMatch struct {
+	Type    string            `xml:"type,attr"`
+	Value   string            `xml:"value,attr"`
+	Offset  string            `xml:"offset,attr"`
+	Mask    string            `xml:"mask,attr"`
+	Matches []sharedMIMEMatch `xml:"match"`
+}
+
+// addSharedMIMEInfo adds the comments, globs and magic rules from a shared-mime-info XML file
+func (db *MIMEDatabase) addSharedMIMEInfo(data []byte) error {
+	var info sharedMIMEInfo
+	if err := xml.Unmarshal(data, &info); err != nil {
+		return err
+	}
+	for _, mimeType := range info.MIMETypes {
+		for _, comment := range mimeType.Comments {
+			if comment.Lang == "" {
+				db.comments[mimeType.Type] = comment.Text
+			}
+		}
+		for _, g := range mimeType.Globs {
+			glob := mimeGlob{pattern: g.Pattern, mimeType: mimeType.Type, weight: g.Weight, caseSensitive: g.CaseSensitive}
+			if glob.weight == 0 {
+				glob.weight = 50
+			}
+			if !glob.caseSensitive {
+				glob.pattern = strings.ToLower(glob.pattern)
+			}
+			ext, isSimple := strings.CutPrefix(glob.pattern, "*.")
+			if isSimple && !glob.caseSensitive && !strings.ContainsAny(ext, "*?[") {
+				if existing, ok := db.simpleGlobs[ext]; !ok || glob.weight > existing.weight {
+					db.simpleGlobs[ext] = glob
+				}
+				continue
+			}
+			db.globs = append(db.globs, glob)
+		}
+		for _, m := range mimeType.Magic {
+			magic := mimeMagic{mimeType: mimeType.Type, priority: m.Priority}
+			if magic.priority == 0 {
+				magic.priority = 50
+			}
+			for _, match := range m.Matches {
+				if parsed := parseMagicMatch(match); parsed != nil {
+					magic.matches = append(magic.matches, parsed)
+				}
+			}
+			if len(magic.matches) > 0 {
+				db.magic = append(db.magic, magic)
+			}
+		}
+	}
+	return nil
+}
+
+// parseMagicMatch converts a magic rule from the XML file. Returns nil if
+// the rule can not be parsed, or if it is of an unsupported type.
+func parseMagicMatch(match sharedMIMEMatch) *magicMatch {
+	parsed := &magicMatch{}
+	start, end, isRange := strings.Cut(match.Offset, ":")
+	var err error
+	if parsed.offset, err = strconv.Atoi(start); err != nil {
+		return nil
+	}
+	parsed.rangeEnd = parsed.offset
+	if isRange {
+		if parsed.rangeEnd, err = strconv.Atoi(end); err != nil {
+			return nil
+		}
+	}
+	if parsed.value = magicValue(match.Type, match.Value); parsed.value == nil {
+		return nil
+	}
+	if match.Mask != "" {
+		if match.Type == "string" {
+			parsed.mask, err = hex.DecodeString(strings.TrimPrefix(match.Mask, "0x"))
+		} else if parsed.mask = magicValue(match.Type, match.Mask); parsed.mask == nil {
+			return nil
+		}
+		if err != nil || len(parsed.mask) != len(parsed.value) {
+			return nil
+		}
+	}
+	for _, child := range match.Matches {
+		if parsedChild := parseMagicMatch(child); parsedChild != nil {
+			parsed.children = append(parsed.children, parsedChild)
+		}
+	}
+	if len(match.Matches) > 0 && len(parsed.children) == 0 {
+		return nil // the rule would be less specific without the child rules
+	}
+	return parsed
+}
+
+// magicValue converts the value of a magic rule to bytes, by the type of
+// the rule. Returns nil for unsupported types and values.
+func magicValue(typ, value string) []byte {
+	if typ == "string" {
+		return unescapeMagic(value)
+	}
+	var (
+		size  int
+		order binary.ByteOrder
+	)
+	switch typ {
+	case "byte":
+		size, order = 1, binary.BigEndian
+	case "big16":
+		size, order = 2, binary.BigEndian
+	case "little16":
+		size, order = 2, binary.LittleEndian
+	case "host16":
+		size, order = 2, binary.NativeEndian
+	case "big32":
+		size, order = 4, binary.BigEndian
+	case "little32":
+		size, order = 4, binary.LittleEndian
+	case "host32":
+		size, order = 4, binary.NativeEndian
+	default:
+		return nil
+	}
+	n, err := strconv.ParseUint(value, 0, size*8)
+	if err != nil {
+		return nil
+	}
+	buf := make([]byte, 4)
+	switch size {
+	case 1:
+		buf[0] = byte(n)
+	case 2:
+		order.PutUint16(buf, uint16(n))
+	case 4:
+		order.PutUint32(buf, uint32(n))
+	}
+	return buf[:size]
+}
+
+// unescapeMagic converts the C-style escapes in a magic string, like "\x89PNG" or "PK\003\004"
+func unescapeMagic(s string) []byte {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			buf = append(buf, s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; {
+		case c == 'x':
+			j := i + 1
+			for j < len(s) && j < i+3 && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0 {
+				j++
+			}
+			n, _ := strconv.ParseUint(s[i+1:j], 16, 8)
+			buf = append(buf, byte(n))
+			i = j - 1
+		case c >= '0' && c <= '7':
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			n, _ := strconv.ParseUint(s[i:j], 8, 8)
+			buf = append(buf, byte(n))
+			i = j - 1
+		case c == 'n':
+			buf = append(buf, '\n')
+		case c == 'r':
+			buf = append(buf, '\r')
+		case c == 't':
+			buf = append(buf, '\t')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+// matches checks if this magic rule, and one of the child rules, matches the given data
+func (match *magicMatch) matches(data []byte) bool {
+	found := false
+	for start := match.offset; start <= match.rangeEnd && start+len(match.value) <= len(data); start++ {
+		if maskedEqual(data[start:start+len(match.value)], match.value, match.mask) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	if len(match.children) == 0 {
+		return true
+	}
+	for _, child := range match.children {
+		if child.matches(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskedEqual compares a and b, only for the bits that are set in the mask, if the mask is not nil
+func maskedEqual(a, b, mask []byte) bool {
+	if mask == nil {
+		return bytes.Equal(a, b)
+	}
+	for i := range a {
+		if a[i]&mask[i] != b[i]&mask[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LookupGlob finds the MIME type of the given filename, by the shared-mime-info
+// globs, /etc/mime.types and then the embedded table. Also returns a
+// description of where the MIME type was found. Returns "" if not found.
+func (db *MIMEDatabase) LookupGlob(filename string) (string, string) {
+	name := filepath.Base(filename)
+	lowerName := strings.ToLower(name)
+
+	var best *mimeGlob
+	for i := range db.globs {
+		glob := &db.globs[i]
+		candidate := lowerName
+		if glob.caseSensitive {
+			candidate = name
+		}
+		if matched, _ := path.Match(glob.pattern, candidate); !matched {
+			continue
+		}
+		if best == nil || glob.weight > best.weight || (glob.weight == best.weight && len(glob.pattern) > len(best.pattern)) {
+			best = glob
+		}
+	}
+	// Try the longest extension first, so that "x.tar.gz" is found before "x.gz"
+	for i := 0; i < len(lowerName); i++ {
+		if lowerName[i] != '.' {
+			continue
+		}
+		if glob, ok := db.simpleGlobs[lowerName[i+1:]]; ok {
+			if best == nil || glob.weight > best.weight || (glob.weight == best.weight && len(glob.pattern) > len(best.pattern)) {
+				best = &glob
+			}
+			break
+		}
+	}
+	if best != nil {
+		return best.mimeType, fmt.Sprintf("the shared-mime-info glob %q", best.pattern)
+	}
+
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	if ext == "" {
+		return "", ""
+	}
+	if mimeType, ok := db.extensions[ext]; ok {
+		return mimeType, mimeTypesPath
+	}
+	if mimeType, ok := embeddedMIMETypes[ext]; ok {
+		return mimeType, "the embedded table"
+	}
+	return "", ""
+}
+
+// LookupMagic finds the MIME type of the given data, by the shared-mime-info
+// magic rules. Returns "" if no rule matches.
+func (db *MIMEDatabase) LookupMagic(data []byte) string {
+	var best *mimeMagic
+	for i := range db.magic {
+		magic := &db.magic[i]
+		if best != nil && magic.priority <= best.priority {
+			continue
+		}
+		for _, match := range magic.matches {
+			if match.matches(data) {
+				best = magic
+				break
+			}
+		}
+	}
+	if best != nil {
+		return best.mimeType
+	}
+	return ""
+}
+
+// Comment returns the description of the given MIME type, like "PNG image", or "" if it is not known
+func (db *MIMEDatabase) Comment(mimeType string) string {
+	return db.comments[mimeType]
+}
+
+// mimeDescription returns a description of the given MIME type, for files
+// that are not recognized in any other way. The shared-mime-info comment is
+// used if there is one, or else the subtype, like "Msword" for "application/msword".
+func mimeDescription(mimeType string) string {
+	if comment := mimeTypes().Comment(mimeType); comment != "" {
+		return strings.ToUpper(comment[:1]) + comment[1:]
+	}
+	_, subtype, found := strings.Cut(mimeType, "/")
+	if !found || subtype == "" {
+		return mimeType
+	}
+	subtype = strings.TrimPrefix(subtype, "x-")
+	return strings.ToUpper(subtype[:1]) + subtype[1:]
+}
+
+// embeddedMIMETypes is used for the most common extensions, when they are
+// not found in /etc/mime.types or the shared-mime-info database
+var embeddedMIMETypes = map[string]string{
+	"7z":     "application/x-7z-compressed",
+	"apk":    "application/vnd.android.package-archive",
+	"avi":    "video/x-msvideo",
+	"avif":   "image/avif",
+	"bmp":    "image/bmp",
+	"bz2":    "application/x-bzip2",
+	"c":      "text/x-csrc",
+	"cpp":    "text/x-c++src",
+	"css":    "text/css",
+	"csv":    "text/csv",
+	"deb":    "application/vnd.debian.binary-package",
+	"doc":    "application/msword",
+	"docx":   "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"exe":    "application/vnd.microsoft.portable-executable",
+	"flac":   "audio/flac",
+	"gif":    "image/gif",
+	"go":     "text/x-go",
+	"gz":     "application/gzip",
+	"h":      "text/x-chdr",
+	"heic":   "image/heic",
+	"htm":    "text/html",
+	"html":   "text/html",
+	"ico":    "image/vnd.microsoft.icon",
+	"iso":    "application/x-cd-image",
+	"jar":    "application/java-archive",
+	"java":   "text/x-java",
+	"jpeg":   "image/jpeg",
+	"jpg":    "image/jpeg",
+	"js":     "text/javascript",
+	"json":   "application/json",
+	"m4a":    "audio/mp4",
+	"md":     "text/markdown",
+	"mid":    "audio/midi",
+	"mkv":    "video/x-matroska",
+	"mov":    "video/quicktime",
+	"mp3":    "audio/mpeg",
+	"mp4":    "video/mp4",
+	"odt":    "application/vnd.oasis.opendocument.text",
+	"oga":    "audio/ogg",
+	"ogg":    "audio/ogg",
+	"ogv":    "video/ogg",
+	"otf":    "font/otf",
+	"pdf":    "application/pdf",
+	"png":    "image/png",
+	"ppt":    "application/vnd.ms-powerpoint",
+	"pptx":   "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"py":     "text/x-python",
+	"rar":    "application/vnd.rar",
+	"rpm":    "application/x-rpm",
+	"rs":     "text/rust",
+	"sh":     "application/x-shellscript",
+	"sqlite": "application/vnd.sqlite3",
+	"svg":    "image/svg+xml",
+	"tar":    "application/x-tar",
+	"tgz":    "application/x-compressed-tar",
+	"tif":    "image/tiff",
+	"tiff":   "image/tiff",
+	"toml":   "application/toml",
+	"ts":     "text/x-typescript",
+	"ttf":    "font/ttf",
+	"txt":    "text/plain",
+	"wasm":   "application/wasm",
+	"wav":    "audio/x-wav",
+	"webm":   "video/webm",
+	"webp":   "image/webp",
+	"woff":   "font/woff",
+	"woff2":  "font/woff2",
+	"xls":    "application/vnd.ms-excel",
+	"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"xml":    "application/xml",
+	"xz":     "application/x-xz",
+	"yaml":   "application/yaml",
+	"yml":    "application/yaml",
+	"zip":    "application/zip",
+	"zst":    "application/zstd",
+}
@@ -25,17 +25,32 @@ func NewModel() (*Model, error) {
 	return &Model{oc, modelName}, nil
 }
 
-func (model *Model) GetBuildCommand(fileOverview string) (string, error) {
+func (model *Model) buildCommandPrompt(fileOverview string) string {
 	distroName := distrodetector.New().Name()
-	prompt := fmt.Sprintf("You are an expert %s developer. Which command can the user run to build or compile a project that has the following files:\n\n%s\n\nAnswer with a command that a script can run directly (no commentary), or just say true. The output will be parsed by a script, so only output the command.", distroName, fileOverview)
-	return model.Ask(fileOverview, prompt)
+	return fmt.Sprintf("You are an expert %s developer. Which command can the user run to build or compile a project that has the following files:\n\n%s\n\nAnswer with a command that a script can run directly (no commentary), or just say true. The output will be parsed by a script, so only output the command.", distroName, fileOverview)
 }
 
-func (model *Model) Ask(fileOverview, prompt string) (string, error) {
-	var sb strings.Builder
+func (model *Model) GetBuildCommand(fileOverview string) (string, error) {
+	return model.Ask(fileOverview, model.buildCommandPrompt(fileOverview))
+}
+
+// BuildCommands returns the suggested build commands, without formatting
+func (model *Model) BuildCommands(fileOverview string) ([]string, error) {
+	buildCommands, err := model.askLines(model.buildCommandPrompt(fileOverview))
+	if err != nil {
+		return nil, err
+	}
+	if l := len(buildCommands); l > 0 && strings.TrimSpace(buildCommands[l-1]) == "true" {
+		return nil, fmt.Errorf("Ollama (%s) could not propose a suitable build command", model.name)
+	}
+	return buildCommands, nil
+}
+
+// askLines sends the prompt to Ollama and returns the lines of the answer, without code fences
+func (model *Model) askLines(prompt string) ([]string, error) {
 	output, err := model.oc.GetOutput(prompt)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	lines := strings.Split(ollamaclient.Massage(output, false), "\n")
 	buildCommands := make([]string, 0)
@@ -45,6 +60,15 @@ func (model *Model) Ask(fileOverview, prompt string) (string, error) {
 		}
 		buildCommands = append(buildCommands, strings.TrimSuffix(strings.TrimPrefix(line, "`"), "`"))
 	}
+	return buildCommands, nil
+}
+
+func (model *Model) Ask(fileOverview, prompt string) (string, error) {
+	var sb strings.Builder
+	buildCommands, err := model.askLines(prompt)
+	if err != nil {
+		return "", err
+	}
 	if l := len(buildCommands); l > 0 {
 		sb.WriteString(fmt.Sprintf("<lightblue>Build %s, suggested by</lightblue> <lightyellow>%s</lightyellow><lightblue>:</lightblue>\n", english.PluralWord(l, "command", ""), model.name))
 		if l > 1 {
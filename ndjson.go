@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// NDJSONWriter writes one JSON record per line, as soon as each entry has
+// been analyzed, so that large directory trees do not have to be held in
+// memory. The records use the same schema version as the --json output.
+type NDJSONWriter struct {
+	mut            sync.Mutex
+	encoder        *json.Encoder
+	fileCount      int
+	directoryCount int
+	ignoredCount   int
+	errorCount     int
+	stats          *Stats
+}
+
+// ndjsonFile is a "file" or "ignored" record
+type ndjsonFile struct {
+	Type string `json:"type"`
+	JSONFile
+}
+
+// ndjsonDirectory is a "directory" record
+type ndjsonDirectory struct {
+	Type     string    `json:"type"`
+	Path     string    `json:"path"`
+	Modified time.Time `json:"mtime"`
+}
+
+// ndjsonError is an "error" record
+type ndjsonError struct {
+	Type string `json:"type"`
+	JSONError
+}
+
+// ndjsonSummary is the "summary" record, which is always the last record
+type ndjsonSummary struct {
+	Type            string      `json:"type"`
+	SchemaVersion   int         `json:"schema_version"`
+	Version         string      `json:"version"`
+	Path            string      `json:"path"`
+	FileCount       int         `json:"file_count"`
+	DirectoryCount  int         `json:"directory_count"`
+	IgnoredCount    int         `json:"ignored_count"`
+	ErrorCount      int         `json:"error_count"`
+	Truncated       bool        `json:"truncated"`
+	Interrupted     bool        `json:"interrupted"`
+	Git             *JSONGit    `json:"git"`
+	BuildSuggestion []string    `json:"build_suggestion"`
+	Stats           []TypeStats `json:"stats"`
+}
+
+// NewNDJSONWriter creates a new NDJSONWriter that writes to the given io.Writer
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{encoder: json.NewEncoder(w), stats: NewStats()}
+}
+
+// write encodes a single record as one line. Must be called with the mutex locked.
+func (nw *NDJSONWriter) write(record any) {
+	_ = nw.encoder.Encode(record) // a closed stdout is not worth reporting for every record
+}
+
+// WriteFile writes a "file" record for an analyzed file
+func (nw *NDJSONWriter) WriteFile(row *FileRow) {
+	nw.mut.Lock()
+	defer nw.mut.Unlock()
+	nw.fileCount++
+	nw.stats.Add(row)
+	nw.write(ndjsonFile{"file", newJSONFile(row)})
+}
+
+// WriteDirectory writes a "directory" record
+func (nw *NDJSONWriter) WriteDirectory(path string, fileInfo os.FileInfo) {
+	nw.mut.Lock()
+	defer nw.mut.Unlock()
+	nw.directoryCount++
+	nw.write(ndjsonDirectory{"directory", path, fileInfo.ModTime()})
+}
+
+// WriteIgnored writes an "ignored" record for an ignored or hidden entry
+func (nw *NDJSONWriter) WriteIgnored(path string, fileInfo os.FileInfo) {
+	nw.mut.Lock()
+	defer nw.mut.Unlock()
+	nw.ignoredCount++
+	nw.write(ndjsonFile{"ignored", newIgnoredJSONFile(path, fileInfo)})
+}
+
+// WriteError writes an "error" record for an entry that could not be read
+func (nw *NDJSONWriter) WriteError(walkError WalkError) {
+	nw.mut.Lock()
+	defer nw.mut.Unlock()
+	nw.errorCount++
+	nw.write(ndjsonError{"error", JSONError{walkError.Path, walkError.Kind, walkError.Err.Error()}})
+}
+
+// WriteSummary writes the final "summary" record
+func (nw *NDJSONWriter) WriteSummary(cfg *Config, findings *Findings, buildSuggestion []string) {
+	nw.mut.Lock()
+	defer nw.mut.Unlock()
+	nw.write(ndjsonSummary{
+		Type:            "summary",
+		SchemaVersion:   jsonSchemaVersion,
+		Version:         versionString,
+		Path:            cfg.path,
+		FileCount:       nw.fileCount,
+		DirectoryCount:  nw.directoryCount,
+		IgnoredCount:    nw.ignoredCount,
+		ErrorCount:      nw.errorCount,
+		Truncated:       findings.truncated,
+		Interrupted:     findings.interrupted,
+		Git:             newJSONGit(cfg.path, findings.git),
+		BuildSuggestion: buildSuggestion,
+		Stats:           nw.stats.Sorted(),
+	})
+}
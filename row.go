@@ -0,0 +1,107 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// sortKeys are the valid values for the --sort flag
+var sortKeys = []string{"mtime", "name", "size", "type", "lines", "ext"}
+
+// FileRow is one row in the file listing
+type FileRow struct {
+	Path string // relative to the examined directory
+	Info os.FileInfo
+	Type FileTypeInfo
+}
+
+// Modified returns the modification time of the file
+func (row *FileRow) Modified() time.Time {
+	return row.Info.ModTime()
+}
+
+// Size returns the size of the file in bytes, or the size of the target for symlinks
+func (row *FileRow) Size() int64 {
+	if row.Type.Link != nil {
+		if row.Type.Link.TargetInfo != nil && row.Type.Link.TargetInfo.Mode().IsRegular() {
+			return row.Type.Link.TargetInfo.Size()
+		}
+		return 0
+	}
+	return row.Info.Size()
+}
+
+// Ext returns the lowercase file extension, including the dot
+func (row *FileRow) Ext() string {
+	return strings.ToLower(filepath.Ext(row.Path))
+}
+
+// SizeDescription returns the number of lines for text files, split into
+// code, comment and blank lines for source code, or the size in bytes
+func (row *FileRow) SizeDescription() string {
+	if row.Type.Link != nil {
+		if row.Type.Link.TargetInfo != nil && row.Type.Link.TargetInfo.Mode().IsRegular() {
+			return humanize.IBytes(uint64(row.Size()))
+		}
+		return "-"
+	}
+	if row.Type.IsBinary || row.Type.LineCount < 0 {
+		return humanize.IBytes(uint64(row.Size()))
+	}
+	if lines := row.Type.Breakdown; lines != nil {
+		return fmt.Sprintf("%d code / %d comment / %d blank", lines.Code, lines.Comment, lines.Blank)
+	}
+	return fmt.Sprintf("%d lines", row.Type.LineCount)
+}
+
+// Cells returns the name, type, time and size cells for this row, with color tags
+func (row *FileRow) Cells() []string {
+	typeInfo := row.Type
+	nameCell := fmt.Sprintf("<%s>%s</%s>", typeInfo.NameColor, row.Path, typeInfo.NameColor)
+	if typeInfo.Link != nil {
+		nameCell += fmt.Sprintf(" <gray>-></gray> <%s>%s</%s>", typeInfo.TypeColor, typeInfo.Link.Target, typeInfo.TypeColor)
+	}
+	typeCell := fmt.Sprintf("[<%s>%s</%s>]", typeInfo.TypeColor, typeInfo.Description, typeInfo.TypeColor) + typeInfo.ColoredBadges()
+	timeCell := TimeString(true, row.Modified(), "lightyellow", "lightblue", "white")
+	return []string{nameCell, typeCell, timeCell, row.SizeDescription()}
+}
+
+// compareRows compares two rows by the given sort key
+func compareRows(a, b *FileRow, key string) int {
+	switch key {
+	case "name":
+		return cmp.Compare(a.Path, b.Path)
+	case "size":
+		return cmp.Compare(a.Size(), b.Size())
+	case "type":
+		return cmp.Compare(a.Type.Description, b.Type.Description)
+	case "lines":
+		return cmp.Compare(a.Type.LineCount, b.Type.LineCount)
+	case "ext":
+		return cmp.Compare(a.Ext(), b.Ext())
+	default: // "mtime"
+		return a.Modified().Compare(b.Modified())
+	}
+}
+
+// SortRows sorts the rows by the given sort key. Rows with equal keys are
+// sorted by path, so that the order is always the same.
+func SortRows(rows []FileRow, key string, reverse bool) {
+	slices.SortStableFunc(rows, func(a, b FileRow) int {
+		c := compareRows(&a, &b, key)
+		if reverse {
+			c = -c
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Path, b.Path)
+		}
+		return c
+	})
+}
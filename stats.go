@@ -0,0 +1,166 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/xyproto/mode"
+)
+
+// Binary and unknown files are grouped separately in the statistics
+const (
+	binaryStatsName  = "Binary"
+	unknownStatsName = "Unknown"
+)
+
+// TypeStats contains the totals for one type of file. The code, comment and
+// blank lines are only counted for source code.
+type TypeStats struct {
+	Name  string `json:"type"`
+	Files int    `json:"files"`
+	Lines int    `json:"lines"`
+	Bytes int64  `json:"bytes"`
+	LineBreakdown
+}
+
+// Stats collects the number of files, lines and bytes for each type of file
+type Stats struct {
+	byType map[string]*TypeStats
+}
+
+// NewStats creates an empty Stats struct
+func NewStats() *Stats {
+	return &Stats{byType: make(map[string]*TypeStats)}
+}
+
+// ComputeStats collects statistics for the given file rows
+func ComputeStats(rows []FileRow) *Stats {
+	stats := NewStats()
+	for i := range rows {
+		stats.Add(&rows[i])
+	}
+	return stats
+}
+
+// Add counts the given file. Symlinks are not counted.
+func (stats *Stats) Add(row *FileRow) {
+	if row.Type.Link != nil {
+		return
+	}
+	name := row.Type.Mode.String()
+	if row.Type.IsBinary {
+		name = binaryStatsName
+	} else if row.Type.Mode == mode.Blank {
+		name = unknownStatsName
+	}
+	typeStats, ok := stats.byType[name]
+	if !ok {
+		typeStats = &TypeStats{Name: name}
+		stats.byType[name] = typeStats
+	}
+	typeStats.Files++
+	if row.Type.LineCount > 0 {
+		typeStats.Lines += row.Type.LineCount
+	}
+	if lines := row.Type.Breakdown; lines != nil {
+		typeStats.Code += lines.Code
+		typeStats.Comment += lines.Comment
+		typeStats.Blank += lines.Blank
+	}
+	typeStats.Bytes += row.Size()
+}
+
+// Sorted returns the statistics for each type, sorted by the number of lines
+// and then bytes. The binary and unknown files are always last.
+func (stats *Stats) Sorted() []TypeStats {
+	var sorted, grouped []TypeStats
+	for _, typeStats := range stats.byType {
+		if typeStats.Name == binaryStatsName || typeStats.Name == unknownStatsName {
+			grouped = append(grouped, *typeStats)
+		} else {
+			sorted = append(sorted, *typeStats)
+		}
+	}
+	byLinesAndBytes := func(a, b TypeStats) int {
+		if c := cmp.Compare(b.Lines, a.Lines); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Bytes, a.Bytes); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	}
+	slices.SortFunc(sorted, byLinesAndBytes)
+	slices.SortFunc(grouped, func(a, b TypeStats) int { return cmp.Compare(a.Name, b.Name) })
+	return append(sorted, grouped...)
+}
+
+// Total returns the sum of all the statistics
+func (stats *Stats) Total() TypeStats {
+	total := TypeStats{Name: "Total"}
+	for _, typeStats := range stats.byType {
+		total.Files += typeStats.Files
+		total.Lines += typeStats.Lines
+		total.Bytes += typeStats.Bytes
+		total.Code += typeStats.Code
+		total.Comment += typeStats.Comment
+		total.Blank += typeStats.Blank
+	}
+	return total
+}
+
+// percentage returns part as a percentage of total
+func percentage(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
+
+// ListStats writes the number of files, lines and bytes for each type of file
+func (cfg *Config) ListStats(ob *strings.Builder, findings *Findings, needsSeparator *bool) {
+	// Statistics for each type of file, if there are files
+	if len(findings.rows) > 0 {
+		if *needsSeparator {
+			ob.WriteString("\n")
+			*needsSeparator = false
+		}
+
+		stats := ComputeStats(findings.rows)
+		total := stats.Total()
+		table := Table{
+			Rows:         [][]string{{"<white>Type</white>", "<white>Files</white>", "<white>Lines</white>", "<white>Code</white>", "<white>Comment</white>", "<white>Blank</white>", "<white>Size</white>", "<white>% of size</white>"}},
+			RightAlign:   []bool{false, true, true, true, true, true, true, true},
+			MaxWidth:     cfg.termWidth,
+			ShrinkColumn: 0,
+			Colors:       cfg.colors,
+		}
+		for _, typeStats := range append(stats.Sorted(), total) {
+			nameColor := "lightgreen"
+			switch typeStats.Name {
+			case binaryStatsName:
+				nameColor = "lightred"
+			case unknownStatsName:
+				nameColor = "gray"
+			case total.Name:
+				nameColor = "white"
+			}
+			table.Rows = append(table.Rows, []string{
+				fmt.Sprintf("<%s>%s</%s>", nameColor, typeStats.Name, nameColor),
+				fmt.Sprintf("%d", typeStats.Files),
+				fmt.Sprintf("%d", typeStats.Lines),
+				fmt.Sprintf("%d", typeStats.Code),
+				fmt.Sprintf("%d", typeStats.Comment),
+				fmt.Sprintf("%d", typeStats.Blank),
+				humanize.IBytes(uint64(typeStats.Bytes)),
+				fmt.Sprintf("%.1f%%", percentage(typeStats.Bytes, total.Bytes)),
+			})
+		}
+		ob.WriteString(table.Render())
+
+		*needsSeparator = true
+	}
+}
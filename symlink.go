@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// LinkInfo contains information about a symbolic link and what it points to
+type LinkInfo struct {
+	Target     string      // the target, as stored in the symlink
+	TargetInfo os.FileInfo // nil if the link is broken or loops
+	Broken     bool        // the target does not exist
+	Loop       bool        // the link resolves to itself, directly or indirectly
+}
+
+// IsSymlink checks if the given os.FileInfo (from os.Lstat) is for a symbolic link
+func IsSymlink(fileInfo os.FileInfo) bool {
+	return fileInfo != nil && fileInfo.Mode()&os.ModeSymlink != 0
+}
+
+// ReadLink examines the symbolic link at the given path
+func ReadLink(path string) (*LinkInfo, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return nil, err
+	}
+	linkInfo := &LinkInfo{Target: target}
+	targetInfo, err := os.Stat(path)
+	switch {
+	case err == nil:
+		linkInfo.TargetInfo = targetInfo
+	case errors.Is(err, syscall.ELOOP):
+		linkInfo.Loop = true
+	case errors.Is(err, os.ErrNotExist):
+		linkInfo.Broken = true
+	default:
+		return nil, err
+	}
+	return linkInfo, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xyproto/textoutput"
+)
+
+const (
+	columnGap      = 2  // spaces between the columns of a table
+	minShrinkWidth = 16 // a column is not shrunk below this width
+)
+
+// plainOutput is used for removing color tags
+var plainOutput = textoutput.NewTextOutput(false, true)
+
+// stripTags removes color tags like <red> and </red> from the given string
+func stripTags(s string) string {
+	return plainOutput.Tags(s)
+}
+
+// wideRanges are the East Asian wide and fullwidth ranges, and emojis,
+// which take up two columns in a terminal
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF},
+	{0x20000, 0x3FFFD},
+}
+
+// runeWidth returns the number of terminal columns that the given rune takes up
+func runeWidth(r rune) int {
+	if r < 0x20 || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	if r < 0x1100 {
+		return 1
+	}
+	for _, wideRange := range wideRanges {
+		if r >= wideRange[0] && r <= wideRange[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// visibleWidth returns the number of terminal columns that the given string
+// takes up, when the color tags have been removed
+func visibleWidth(s string) int {
+	width := 0
+	for _, r := range stripTags(s) {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// tagOrRune is either a color tag or a single visible rune
+type tagOrRune struct {
+	tag  string
+	r    rune
+	text string // the bytes of the rune, which are kept as they are if they are not valid UTF-8
+}
+
+// splitTags splits a string with color tags into tags and visible runes
+func splitTags(s string) []tagOrRune {
+	var parts []tagOrRune
+	for i := 0; i < len(s); {
+		if s[i] == '<' {
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				if candidate := s[i : i+end+1]; stripTags(candidate) == "" {
+					parts = append(parts, tagOrRune{tag: candidate})
+					i += end + 1
+					continue
+				}
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		parts = append(parts, tagOrRune{r: r, text: s[i : i+size]})
+		i += size
+	}
+	return parts
+}
+
+// ellipsizeLeft shortens the given string with color tags to the given visible
+// width, by replacing the start with "…". The color tags are kept, so that the
+// remaining text is colored the same way.
+func ellipsizeLeft(s string, width int) string {
+	if visibleWidth(s) <= width {
+		return s
+	}
+	parts := splitTags(s)
+	// Find the first rune that is kept, leaving room for the ellipsis
+	first, used := len(parts), 1
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i].tag != "" {
+			continue
+		}
+		if w := runeWidth(parts[i].r); used+w <= width {
+			used += w
+			first = i
+		} else {
+			break
+		}
+	}
+	var sb strings.Builder
+	ellipsisWritten := false
+	for i, part := range parts {
+		if part.tag != "" {
+			sb.WriteString(part.tag)
+			continue
+		}
+		if i < first {
+			continue
+		}
+		if !ellipsisWritten {
+			sb.WriteString("…")
+			ellipsisWritten = true
+		}
+		sb.WriteString(part.text)
+	}
+	return sb.String()
+}
+
+// Table renders rows of cells that may contain color tags, as aligned columns
+type Table struct {
+	Rows         [][]string
+	RightAlign   []bool // which columns to align to the right
+	MaxWidth     int    // the maximum width of a line, or 0 for no limit
+	ShrinkColumn int    // the column that is ellipsized if the lines are too wide
+	Colors       bool   // keep the color tags, or strip them
+}
+
+// Render returns the table as lines of text
+func (t *Table) Render() string {
+	var widths []int
+	for _, cells := range t.Rows {
+		for j, cell := range cells {
+			if j >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[j] = max(widths[j], visibleWidth(cell))
+		}
+	}
+	if len(widths) == 0 {
+		return ""
+	}
+
+	// Shrink a column if the lines are too wide for the terminal
+	shrinkTo := -1
+	if t.MaxWidth > 0 && t.ShrinkColumn < len(widths) {
+		total := columnGap * (len(widths) - 1)
+		for _, width := range widths {
+			total += width
+		}
+		if excess := total - t.MaxWidth; excess > 0 {
+			shrinkTo = max(minShrinkWidth, widths[t.ShrinkColumn]-excess)
+			widths[t.ShrinkColumn] = min(widths[t.ShrinkColumn], shrinkTo)
+		}
+	}
+
+	var sb strings.Builder
+	for _, cells := range t.Rows {
+		for j, cell := range cells {
+			if j == t.ShrinkColumn && shrinkTo >= 0 {
+				cell = ellipsizeLeft(cell, shrinkTo)
+			}
+			if !t.Colors {
+				cell = stripTags(cell)
+			}
+			padding := strings.Repeat(" ", widths[j]-visibleWidth(cell))
+			last := j == len(cells)-1
+			if j < len(t.RightAlign) && t.RightAlign[j] {
+				sb.WriteString(padding)
+				sb.WriteString(cell)
+			} else {
+				sb.WriteString(cell)
+				if !last {
+					sb.WriteString(padding)
+				}
+			}
+			if !last {
+				sb.WriteString(strings.Repeat(" ", columnGap))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestEllipsizeLeft(t *testing.T) {
+	for _, tc := range []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"<red>abcdef</red>", 4, "<red>…def</red>"},
+		{"dir/blåbær.txt", 9, "…åbær.txt"},
+		{"caf\xe9-\xe9t\xe9.txt", 8, "…\xe9t\xe9.txt"}, // Latin-1, which is not valid UTF-8
+	} {
+		if got := ellipsizeLeft(tc.s, tc.width); got != tc.want {
+			t.Errorf("ellipsizeLeft(%q, %d) = %q, want %q", tc.s, tc.width, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/xyproto/mode"
+)
+
+// TextFormat contains the encoding, line endings and indentation of a text file
+type TextFormat struct {
+	Encoding            string `json:"encoding"`             // "ASCII", "UTF-8", "UTF-8 BOM", "UTF-16LE", "UTF-16BE" or "Latin-1"
+	LineEndings         string `json:"line_endings"`         // "LF", "CRLF", "CR", "mixed", or "" if there are no line breaks
+	FinalNewline        bool   `json:"final_newline"`        // true if the file ends with a line break
+	Indentation         string `json:"indentation"`          // "tabs", "spaces", "mixed", or "" if no lines are indented
+	ExpectedIndentation string `json:"expected_indentation"` // "tabs" or "spaces", by the conventions of the language, or ""
+}
+
+// DetectEncoding returns the text encoding of the given data, by the byte
+// order mark, the pattern of zero bytes or by checking if it is valid UTF-8.
+// Data that is not valid UTF-8 is assumed to be Latin-1.
+func DetectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		return "UTF-8 BOM"
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return "UTF-16LE"
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return "UTF-16BE"
+	}
+	if encoding := detectUTF16WithoutBOM(data); encoding != "" {
+		return encoding
+	}
+	// The data may be the first block of a large file, which can end in the middle of a rune
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				data = data[:i]
+			}
+			break
+		}
+	}
+	if !utf8.Valid(data) {
+		return "Latin-1"
+	}
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return "UTF-8"
+		}
+	}
+	return "ASCII"
+}
+
+// detectUTF16WithoutBOM checks if the first bytes of the given data look like
+// UTF-16 encoded ASCII text, where every other byte is zero
+func detectUTF16WithoutBOM(data []byte) string {
+	sample := data[:min(len(data), 512)&^1]
+	if len(sample) < 4 {
+		return ""
+	}
+	var evenZeros, oddZeros int
+	for i := 0; i < len(sample); i += 2 {
+		if sample[i] == 0 {
+			evenZeros++
+		}
+		if sample[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	pairs := len(sample) / 2
+	switch {
+	case oddZeros == pairs && evenZeros == 0:
+		return "UTF-16LE"
+	case evenZeros == pairs && oddZeros == 0:
+		return "UTF-16BE"
+	}
+	return ""
+}
+
+// decodeUTF16 converts the given UTF-16 data to UTF-8, without the byte order mark
+func decodeUTF16(data []byte, encoding string) []byte {
+	var order binary.ByteOrder = binary.LittleEndian
+	if encoding == "UTF-16BE" {
+		order = binary.BigEndian
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, order.Uint16(data[i:]))
+	}
+	if len(units) > 0 && units[0] == 0xfeff {
+		units = units[1:]
+	}
+	var buf bytes.Buffer
+	for _, r := range utf16.Decode(units) {
+		buf.WriteRune(r)
+	}
+	return buf.Bytes()
+}
+
+// isMostlyPrintable checks if at least 95% of the runes in the given UTF-8
+// text are printable or whitespace. Binary data that starts with a UTF-16
+// byte order mark decodes to mostly unassigned, private use and replacement runes.
+func isMostlyPrintable(text []byte) bool {
+	var printable, total int
+	for _, r := range string(text) {
+		total++
+		if r != utf8.RuneError && (unicode.IsPrint(r) || unicode.IsSpace(r)) {
+			printable++
+		}
+	}
+	return total > 0 && printable*100 >= total*95
+}
+
+// AnalyzeText finds the line endings and indentation of the given UTF-8
+// text. The encoding is the encoding of the original data, and m is used for
+// finding the expected indentation.
+func AnalyzeText(data []byte, encoding string, m mode.Mode) *TextFormat {
+	textFormat := &TextFormat{
+		Encoding:     encoding,
+		FinalNewline: bytes.HasSuffix(data, []byte{'\n'}) || bytes.HasSuffix(data, []byte{'\r'}),
+	}
+
+	var lf, crlf, cr int
+	for i, b := range data {
+		switch {
+		case b == '\n' && i > 0 && data[i-1] == '\r':
+			crlf++
+		case b == '\n':
+			lf++
+		case b == '\r' && (i+1 == len(data) || data[i+1] != '\n'):
+			cr++
+		}
+	}
+	var kinds []string
+	for _, kind := range []struct {
+		name  string
+		count int
+	}{{"LF", lf}, {"CRLF", crlf}, {"CR", cr}} {
+		if kind.count > 0 {
+			kinds = append(kinds, kind.name)
+		}
+	}
+	switch len(kinds) {
+	case 0:
+	case 1:
+		textFormat.LineEndings = kinds[0]
+	default:
+		textFormat.LineEndings = "mixed"
+	}
+
+	// Lines that start with a single space, like the " * " in block comments, do not count as indented
+	var tabLines, spaceLines int
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		switch {
+		case bytes.HasPrefix(line, []byte{'\t'}):
+			tabLines++
+		case bytes.HasPrefix(line, []byte("  ")) && len(bytes.TrimSpace(line)) > 0:
+			spaceLines++
+		}
+	}
+	// A few lines with the other kind of indentation, like in a multiline string, are not counted as mixed
+	switch {
+	case min(tabLines, spaceLines) > (tabLines+spaceLines)/4:
+		textFormat.Indentation = "mixed"
+	case tabLines > spaceLines:
+		textFormat.Indentation = "tabs"
+	case spaceLines > 0:
+		textFormat.Indentation = "spaces"
+	}
+
+	// go.mod files are indented with tabs by "go mod tidy"
+	switch {
+	case m == mode.Blank || m == mode.Text:
+	case m.Spaces() && m != mode.GoMod:
+		textFormat.ExpectedIndentation = "spaces"
+	default:
+		textFormat.ExpectedIndentation = "tabs"
+	}
+
+	return textFormat
+}
+
+// Badges returns short notes about anything that is unusual for a text file
+// on Linux, like "CRLF" or "no final newline"
+func (textFormat *TextFormat) Badges() []string {
+	var badges []string
+	if textFormat.Encoding != "ASCII" && textFormat.Encoding != "UTF-8" {
+		badges = append(badges, textFormat.Encoding)
+	}
+	switch textFormat.LineEndings {
+	case "CRLF", "CR":
+		badges = append(badges, textFormat.LineEndings)
+	case "mixed":
+		badges = append(badges, "mixed line endings")
+	}
+	if !textFormat.FinalNewline {
+		badges = append(badges, "no final newline")
+	}
+	switch {
+	case textFormat.Indentation == "mixed":
+		badges = append(badges, "mixed indentation")
+	case textFormat.ExpectedIndentation != "" && textFormat.Indentation != "" && textFormat.Indentation != textFormat.ExpectedIndentation:
+		badges = append(badges, "indented with "+textFormat.Indentation)
+	}
+	return badges
+}
+
+// ColoredBadges returns the badges of the given file type, with color tags and a leading space, or ""
+func (typeInfo *FileTypeInfo) ColoredBadges() string {
+	if typeInfo.Text == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, badge := range typeInfo.Text.Badges() {
+		sb.WriteString(" <yellow>" + badge + "</yellow>")
+	}
+	return sb.String()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestIsMostlyPrintable(t *testing.T) {
+	noise := make([]byte, 512)
+	rand.New(rand.NewSource(1)).Read(noise)
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"UTF-16LE text", []byte("\xff\xfeh\x00i\x00 \x00\xe5\x00\n\x00"), true},
+		{"UTF-16BE text", []byte("\xfe\xff\x00h\x00i\x00\n"), true},
+		{"binary data after a byte order mark", append([]byte{0xff, 0xfe}, noise...), false},
+		{"only a byte order mark", []byte{0xff, 0xfe}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			encoding := DetectEncoding(tc.data)
+			if got := isMostlyPrintable(decodeUTF16(tc.data, encoding)); got != tc.want {
+				t.Errorf("got %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
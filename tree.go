@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dustin/go-humanize"
+)
+
+// maxIgnoredCount is the maximum number of entries that are counted in each ignored directory
+const maxIgnoredCount = 10000
+
+// treeNode is a file or directory in the tree view
+type treeNode struct {
+	name         string
+	isDir        bool
+	ignored      bool
+	ignoredCount int      // the number of entries in an ignored directory
+	ignoredMore  bool     // there are more than ignoredCount entries, which were not counted
+	unwalked     bool     // a directory at the depth limit, which was not walked
+	partial      bool     // a directory that contains unwalked directories, so the size is a lower bound
+	size         int64    // the size of a file, or the total size of the files in a directory
+	row          *FileRow // nil for directories and ignored entries
+	children     map[string]*treeNode
+}
+
+// child returns the child node with the given name, creating a directory node if needed
+func (node *treeNode) child(name string) *treeNode {
+	if node.children == nil {
+		node.children = make(map[string]*treeNode)
+	}
+	c, ok := node.children[name]
+	if !ok {
+		c = &treeNode{name: name, isDir: true}
+		node.children[name] = c
+	}
+	return c
+}
+
+// add returns the node for the given path, creating the nodes along the way
+func (node *treeNode) add(path string) *treeNode {
+	for _, name := range SplitPath(path) {
+		node = node.child(name)
+	}
+	return node
+}
+
+// rollUp sums up the sizes of the files in each directory
+func (node *treeNode) rollUp() int64 {
+	if node.isDir && !node.ignored {
+		node.size = 0
+		for _, c := range node.children {
+			node.size += c.rollUp()
+			node.partial = node.partial || c.unwalked || c.partial
+		}
+	}
+	if node.ignored {
+		return 0
+	}
+	return node.size
+}
+
+// sortedChildren returns the child nodes, with directories first and then sorted by name
+func (node *treeNode) sortedChildren() []*treeNode {
+	children := make([]*treeNode, 0, len(node.children))
+	for _, c := range node.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].isDir != children[j].isDir {
+			return children[i].isDir
+		}
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// label returns the name of the node with color tags and a short annotation
+func (node *treeNode) label() string {
+	switch {
+	case node.ignored && node.isDir:
+		if node.ignoredMore {
+			return fmt.Sprintf("<gray>%s/ (%d+ ignored)</gray>", node.name, node.ignoredCount)
+		}
+		return fmt.Sprintf("<gray>%s/ (%d ignored)</gray>", node.name, node.ignoredCount)
+	case node.ignored:
+		return fmt.Sprintf("<gray>%s (ignored)</gray>", node.name)
+	case node.isDir:
+		return fmt.Sprintf("<lightcyan>%s</lightcyan><lightgreen>/</lightgreen> <gray>(%s)</gray>", node.name, node.sizeDescription())
+	}
+	typeInfo := node.row.Type
+	name := fmt.Sprintf("<%s>%s</%s>", typeInfo.NameColor, node.name, typeInfo.NameColor)
+	if typeInfo.Link != nil {
+		name += fmt.Sprintf(" <gray>-></gray> <%s>%s</%s>", typeInfo.TypeColor, typeInfo.Link.Target, typeInfo.TypeColor)
+	}
+	return fmt.Sprintf("%s [<%s>%s</%s>]%s <gray>%s</gray>", name, typeInfo.TypeColor, typeInfo.Description, typeInfo.TypeColor, typeInfo.ColoredBadges(), node.row.SizeDescription())
+}
+
+// sizeDescription returns the total size of a directory, with a "+" if
+// some directories below it were not walked, or "not walked"
+func (node *treeNode) sizeDescription() string {
+	switch {
+	case node.unwalked:
+		return "not walked"
+	case node.partial:
+		return humanize.IBytes(uint64(node.size)) + "+"
+	}
+	return humanize.IBytes(uint64(node.size))
+}
+
+// render writes the children of this node, with box-drawing characters
+func (node *treeNode) render(sb *strings.Builder, prefix string) {
+	children := node.sortedChildren()
+	for i, c := range children {
+		branch, indent := "├── ", "│   "
+		if i == len(children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		sb.WriteString("<gray>" + prefix + branch + "</gray>")
+		sb.WriteString(c.label())
+		sb.WriteString("\n")
+		if !c.ignored {
+			c.render(sb, prefix+indent)
+		}
+	}
+}
+
+// countEntries returns the number of files and directories below the given
+// directory, up to maxIgnoredCount. complete is false if there are more.
+func countEntries(dir string) (count int, complete bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var counter atomic.Int64 // directories are walked concurrently
+	_ = Walk(ctx, dir, func(path string, _ os.FileInfo, err error) error {
+		if err == nil && path != "" && counter.Add(1) > maxIgnoredCount {
+			cancel() // one more than the maximum is enough to know that there are more
+		}
+		return nil
+	})
+	total := counter.Load()
+	return int(min(total, maxIgnoredCount)), total <= maxIgnoredCount
+}
+
+// ListTree writes the findings as an indented tree
+func (cfg *Config) ListTree(ob *strings.Builder, findings *Findings, needsSeparator *bool) {
+	if *needsSeparator {
+		ob.WriteString("\n")
+		*needsSeparator = false
+	}
+
+	// The path is "." if no path was given, so use the name of the current directory
+	rootPath := cfg.path
+	if absPath, err := filepath.Abs(cfg.path); err == nil { // success
+		rootPath = absPath
+	}
+	root := &treeNode{name: filepath.Base(rootPath), isDir: true}
+	for _, dirName := range findings.dirList {
+		node := root.add(dirName)
+		node.unwalked = cfg.maxDepth >= 0 && len(SplitPath(dirName)) >= cfg.maxDepth
+	}
+	for i := range findings.rows {
+		row := &findings.rows[i]
+		node := root.add(row.Path)
+		node.isDir = false
+		node.row = row
+		node.size = row.Size()
+	}
+	for _, fn := range findings.ignoredFiles {
+		node := root.add(fn)
+		node.ignored = true
+		if fInfo, ok := findings.infoMap[fn]; ok && fInfo.IsDir() {
+			count, complete := countEntries(filepath.Join(cfg.path, fn))
+			node.ignoredCount, node.ignoredMore = count, !complete
+		} else {
+			node.isDir = false
+		}
+	}
+	root.rollUp()
+
+	ob.WriteString(fmt.Sprintf("<lightcyan>%s</lightcyan><lightgreen>/</lightgreen> <gray>(%s)</gray>\n", root.name, root.sizeDescription()))
+	var sb strings.Builder
+	root.render(&sb, "")
+	ob.WriteString(sb.String())
+
+	*needsSeparator = true
+}
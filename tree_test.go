@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCountEntries(t *testing.T) {
+	dir := t.TempDir()
+	for i := range maxIgnoredCount {
+		if err := os.WriteFile(filepath.Join(dir, strconv.Itoa(i)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count, complete := countEntries(dir); count != maxIgnoredCount || !complete {
+		t.Errorf("with %d entries, got %d, %t", maxIgnoredCount, count, complete)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "one-more"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if count, complete := countEntries(dir); count != maxIgnoredCount || complete {
+		t.Errorf("with %d entries, got %d, %t", maxIgnoredCount+1, count, complete)
+	}
+}
+
+func TestTreeSizes(t *testing.T) {
+	root := &treeNode{name: "root", isDir: true}
+	addFile := func(path string, size int64) {
+		node := root.add(path)
+		node.isDir, node.size = false, size
+	}
+	addFile("src/main.go", 100)
+	addFile("docs/index.md", 50)
+	root.add("src/internal").unwalked = true
+	root.rollUp()
+	for _, tc := range []struct {
+		node *treeNode
+		want string
+	}{
+		{root, "150 B+"},
+		{root.add("src"), "100 B+"},
+		{root.add("src/internal"), "not walked"},
+		{root.add("docs"), "50 B"},
+	} {
+		if got := tc.node.sizeDescription(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.node.name, got, tc.want)
+		}
+	}
+	if label := root.add("src/internal").label(); !strings.Contains(label, "(not walked)") {
+		t.Errorf("got %q", label)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/xyproto/mode"
+	"github.com/xyproto/vt100"
+)
+
+// typeRulesFilename is the name of the file with user-defined type rules, in ~/.config/pal
+const typeRulesFilename = "types.json"
+
+// typeRules are the user-defined type rules, which are loaded before the files are analyzed
+var typeRules []*TypeRule
+
+// TypeRule is a user-defined file type. All the conditions that are given
+// (glob, shebang and magic) must match. The first matching rule is used,
+// before any of the built-in file type detection.
+type TypeRule struct {
+	Glob        string `json:"glob"`        // a pattern for the filename, like "*.tmpl.yaml"
+	Shebang     string `json:"shebang"`     // the name of the interpreter in the first line, like "bazel"
+	Magic       string `json:"magic"`       // the first bytes, with escapes like "\\x89HDF"
+	Offset      int    `json:"offset"`      // the offset of the magic bytes
+	Description string `json:"description"` // the description that is shown in the listing
+	Mode        string `json:"mode"`        // the name of a mode, like "Starlark" or "YAML"
+	TypeColor   string `json:"type_color"`
+	NameColor   string `json:"name_color"`
+
+	mode  mode.Mode
+	magic []byte
+}
+
+// typeRulesFile is the structure of the type rules file
+type typeRulesFile struct {
+	Rules []*TypeRule `json:"rules"`
+}
+
+// defaultTypeRulesPath returns $XDG_CONFIG_HOME/pal/types.json or ~/.config/pal/types.json, or "" if there is no home directory
+func defaultTypeRulesPath() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "pal", typeRulesFilename)
+}
+
+// LoadTypeRules reads and checks the type rules in the given file
+func LoadTypeRules(filename string) ([]*TypeRule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var rulesFile typeRulesFile
+	if err := json.Unmarshal(data, &rulesFile); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	for i, rule := range rulesFile.Rules {
+		if err := rule.prepare(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", filename, i+1, err)
+		}
+	}
+	return rulesFile.Rules, nil
+}
+
+// modeByName finds the mode with the given name, like "Go", ignoring case
+func modeByName(name string) (mode.Mode, bool) {
+	for m := mode.Mode(mode.Blank + 1); m <= mode.Zig; m++ {
+		if strings.EqualFold(m.String(), name) {
+			return m, true
+		}
+	}
+	return mode.Blank, false
+}
+
+// prepare checks the rule, and converts the mode name and the magic bytes
+func (rule *TypeRule) prepare() error {
+	if rule.Glob == "" && rule.Shebang == "" && rule.Magic == "" {
+		return errors.New("a glob, shebang or magic is needed")
+	}
+	if rule.Glob != "" {
+		if _, err := path.Match(rule.Glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q", rule.Glob)
+		}
+	}
+	if rule.Offset < 0 {
+		return errors.New("the offset can not be negative")
+	}
+	rule.magic = unescapeMagic(rule.Magic)
+	rule.mode = mode.Blank
+	if rule.Mode != "" {
+		m, ok := modeByName(rule.Mode)
+		if !ok {
+			return fmt.Errorf("unknown mode %q", rule.Mode)
+		}
+		rule.mode = m
+	}
+	if rule.Description == "" && rule.Mode == "" {
+		return errors.New("a description or mode is needed")
+	}
+	for _, color := range []string{rule.TypeColor, rule.NameColor} {
+		if _, ok := vt100.DarkColorMap[color]; color != "" && !ok {
+			return fmt.Errorf("unknown color %q", color)
+		}
+	}
+	return nil
+}
+
+// String returns the conditions of the rule, like `glob "*.bzl"`
+func (rule *TypeRule) String() string {
+	var conditions []string
+	if rule.Glob != "" {
+		conditions = append(conditions, fmt.Sprintf("glob %q", rule.Glob))
+	}
+	if rule.Shebang != "" {
+		conditions = append(conditions, fmt.Sprintf("shebang %q", rule.Shebang))
+	}
+	if rule.Magic != "" {
+		conditions = append(conditions, fmt.Sprintf("magic %q at offset %d", rule.Magic, rule.Offset))
+	}
+	return strings.Join(conditions, ", ")
+}
+
+// Matches checks if the rule matches the given filename and data. Rules with
+// shebangs or magic bytes do not match if the data has not been read.
+func (rule *TypeRule) Matches(filename string, data []byte) bool {
+	if rule.Glob != "" {
+		if matched, _ := path.Match(rule.Glob, filepath.Base(filename)); !matched {
+			return false
+		}
+	}
+	if rule.Shebang != "" && (data == nil || shebangInterpreter(data) != rule.Shebang) {
+		return false
+	}
+	if rule.Magic != "" {
+		end := rule.Offset + len(rule.magic)
+		if data == nil || len(data) < end || !bytes.Equal(data[rule.Offset:end], rule.magic) {
+			return false
+		}
+	}
+	return true
+}
+
+// shebangInterpreter returns the name of the interpreter in the first line,
+// like "python3" for "#!/usr/bin/env python3", or "" if there is no shebang
+func shebangInterpreter(data []byte) string {
+	if !bytes.HasPrefix(data, []byte("#!")) {
+		return ""
+	}
+	firstLine, _, _ := bytes.Cut(data[2:min(len(data), 256)], []byte{'\n'})
+	fields := strings.Fields(string(firstLine))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := path.Base(fields[0])
+	// Skip "env" and its flags, like in "#!/usr/bin/env -S deno run"
+	if interpreter == "env" {
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				return path.Base(field)
+			}
+		}
+		return ""
+	}
+	return interpreter
+}
+
+// matchTypeRule returns the first user-defined type rule that matches, or nil
+func matchTypeRule(filename string, data []byte) *TypeRule {
+	for _, rule := range typeRules {
+		if rule.Matches(filename, data) {
+			return rule
+		}
+	}
+	return nil
+}
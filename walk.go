@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// walker walks a directory tree concurrently. Unlike filepath.Walk, the order
+// in which entries are visited is not defined.
+type walker struct {
+	ctx      context.Context
+	root     string
+	follow   bool
+	walkFunc filepath.WalkFunc
+	wg       sync.WaitGroup
+	sem      chan struct{}
+	errMut   sync.Mutex
+	errs     []error
+}
+
+// ancestor is a directory on the path from the root to the directory that is
+// being read, which is used for finding symlink loops when following symlinks
+type ancestor struct {
+	id     fileID
+	parent *ancestor
+}
+
+// contains checks if the directory with the given ID is this directory or one of its parents
+func (a *ancestor) contains(id fileID) bool {
+	for ; a != nil; a = a.parent {
+		if a.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Walk calls walkFn for every entry below root, with a path that is relative
+// to root. The root itself is passed in as "". Returning filepath.SkipDir for
+// a directory prevents the walker from descending into it, while the rest of
+// the parent directory is still visited. Other errors returned by walkFn are
+// collected and returned together when the walk has completed.
+// If a directory can not be read, walkFn is called a second time for that
+// directory, with the error, like filepath.Walk does.
+// Symbolic links are passed to walkFn, but are not followed.
+// If ctx is cancelled, the walk stops early and ctx.Err() is returned.
+func Walk(ctx context.Context, root string, walkFn filepath.WalkFunc) error {
+	w := &walker{
+		ctx:      ctx,
+		root:     root,
+		walkFunc: walkFn,
+		sem:      make(chan struct{}, runtime.GOMAXPROCS(0)),
+	}
+	return w.walk()
+}
+
+// WalkWithSymlinks is like Walk, but also descends into symlinked directories.
+// walkFn is given the os.FileInfo of the symlink itself. A directory is not
+// entered if it is one of its own parents, based on the device and inode
+// numbers, so symlink loops and links back up the tree are not followed. A
+// directory that is linked from several places is walked once for each path.
+func WalkWithSymlinks(ctx context.Context, root string, walkFn filepath.WalkFunc) error {
+	w := &walker{
+		ctx:      ctx,
+		root:     root,
+		follow:   true,
+		walkFunc: walkFn,
+		sem:      make(chan struct{}, runtime.GOMAXPROCS(0)),
+	}
+	return w.walk()
+}
+
+func (w *walker) walk() error {
+	info, err := os.Lstat(w.root)
+	if err = w.walkFunc("", info, err); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	if w.follow && IsSymlink(info) {
+		info, err = os.Stat(w.root)
+		if err != nil {
+			return err
+		}
+	}
+	if info == nil || !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", w.root)
+	}
+	parents, _ := w.enter(info, nil)
+	w.wg.Add(1)
+	go w.walkDir("", info, parents)
+	w.wg.Wait()
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Join(w.errs...)
+}
+
+// enter returns the chain of parents for the given directory, and false if
+// the directory is one of its own parents. Always returns true when not
+// following symlinks, since there can be no loops then.
+func (w *walker) enter(dirInfo os.FileInfo, parents *ancestor) (*ancestor, bool) {
+	if !w.follow {
+		return nil, true
+	}
+	id, ok := getFileID(dirInfo)
+	if !ok {
+		return parents, true
+	}
+	if parents.contains(id) {
+		return nil, false
+	}
+	return &ancestor{id: id, parent: parents}, true
+}
+
+func (w *walker) addError(err error) {
+	w.errMut.Lock()
+	w.errs = append(w.errs, err)
+	w.errMut.Unlock()
+}
+
+// readError reports an error for a directory that could not be read
+func (w *walker) readError(relpath string, info os.FileInfo, err error) {
+	if err = w.walkFunc(relpath, info, err); err != nil && err != filepath.SkipDir {
+		w.addError(err)
+	}
+}
+
+// walkDir reads the directory at relpath and calls the walk function for each
+// entry. parents is the chain of directories from the root to this directory.
+func (w *walker) walkDir(relpath string, dirInfo os.FileInfo, parents *ancestor) {
+	defer w.wg.Done()
+
+	// Limit the number of directories that are read at the same time
+	select {
+	case w.sem <- struct{}{}:
+	case <-w.ctx.Done():
+		return
+	}
+	f, err := os.Open(filepath.Join(w.root, relpath))
+	if err != nil {
+		<-w.sem
+		w.readError(relpath, dirInfo, err)
+		return
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	<-w.sem
+	if err != nil {
+		w.readError(relpath, dirInfo, err)
+		return
+	}
+
+	for _, name := range names {
+		if w.ctx.Err() != nil {
+			return
+		}
+		subpath := filepath.Join(relpath, name)
+		info, err := os.Lstat(filepath.Join(w.root, subpath))
+		if err = w.walkFunc(subpath, info, err); err != nil {
+			if err != filepath.SkipDir {
+				w.addError(err)
+			}
+			continue
+		}
+		if info == nil {
+			continue
+		}
+		if w.follow && IsSymlink(info) {
+			if targetInfo, err := os.Stat(filepath.Join(w.root, subpath)); err == nil { // success
+				info = targetInfo
+			}
+		}
+		if !info.IsDir() {
+			continue
+		}
+		if subParents, ok := w.enter(info, parents); ok {
+			w.wg.Add(1)
+			go w.walkDir(subpath, info, subParents)
+		}
+	}
+}
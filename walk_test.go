@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// walkPaths returns the sorted paths that are passed to the walk function
+func walkPaths(t *testing.T, root string, follow bool) []string {
+	t.Helper()
+	var (
+		mut   sync.Mutex // the walk function is called concurrently
+		paths []string
+	)
+	walkFn := func(path string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		mut.Lock()
+		paths = append(paths, filepath.ToSlash(path))
+		mut.Unlock()
+		return nil
+	}
+	walk := Walk
+	if follow {
+		walk = WalkWithSymlinks
+	}
+	if err := walk(context.Background(), root, walkFn); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+func TestWalkWithSymlinks(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"d", "e/sub"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"d/f.txt", "e/sub/g.txt"} {
+		if err := os.WriteFile(filepath.Join(root, file), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"dl":        "d",     // a sibling of the directory it links to
+		"d/self":    ".",     // a loop to the directory itself
+		"e/sub/top": "../..", // a link back up to the root
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skip("symlinks are not supported:", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		follow bool
+		want   []string
+	}{
+		{"without following symlinks", false, []string{"", "d", "d/f.txt", "d/self", "dl", "e", "e/sub", "e/sub/g.txt", "e/sub/top"}},
+		// Both the directory and the symlink to it are walked, but the loops are not followed
+		{"following symlinks", true, []string{"", "d", "d/f.txt", "d/self", "dl", "dl/f.txt", "dl/self", "e", "e/sub", "e/sub/g.txt", "e/sub/top"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The order of readdir and of the goroutines varies, so walk several times
+			for range 20 {
+				if got := walkPaths(t, root, tc.follow); !slices.Equal(got, tc.want) {
+					t.Fatalf("got %q, want %q", got, tc.want)
+				}
+			}
+		})
+	}
+}
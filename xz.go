@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// maxWindowSize is the largest LZMA2 dictionary or Zstandard window that is
+// accepted, which is larger than what "xz -9" and "zstd -19" use
+const maxWindowSize = 1 << 28
+
+// errCorrupt is returned when compressed data can not be decoded
+var errCorrupt = errors.New("corrupt compressed data")
+
+// xzReader decompresses a single XZ stream that only uses the LZMA2 filter,
+// which is what "xz" and "tar -J" create by default. The checksums of the
+// uncompressed data are not verified.
+type xzReader struct {
+	r     *bufio.Reader
+	check int // the size of the check field after each block
+	lzma2 *lzma2Reader
+	err   error
+}
+
+// newXZReader reads the XZ stream header and returns a reader for the uncompressed data
+func newXZReader(r io.Reader) (*xzReader, error) {
+	br := bufio.NewReader(r)
+	var header [12]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:6], []byte("\xfd7zXZ\x00")) || header[6] != 0 || header[7] > 0x0f {
+		return nil, errCorrupt
+	}
+	if crc32.ChecksumIEEE(header[6:8]) != binary.LittleEndian.Uint32(header[8:]) {
+		return nil, errCorrupt
+	}
+	// The check is CRC32 (1), CRC64 (4) or SHA-256 (10), but the size is defined for all 16 IDs
+	checkSizes := [16]int{0, 4, 4, 4, 8, 8, 8, 16, 16, 16, 32, 32, 32, 64, 64, 64}
+	return &xzReader{r: br, check: checkSizes[header[7]]}, nil
+}
+
+func (z *xzReader) Read(p []byte) (int, error) {
+	for z.err == nil {
+		if z.lzma2 == nil {
+			z.err = z.readBlockHeader()
+			continue
+		}
+		n, err := z.lzma2.Read(p)
+		if err == io.EOF {
+			err = z.skipBlockPadding()
+		}
+		if err != nil {
+			z.err = err
+		}
+		if n > 0 || z.err != nil {
+			return n, z.err
+		}
+	}
+	return 0, z.err
+}
+
+// readBlockHeader starts decoding the next block, or returns io.EOF if the index follows
+func (z *xzReader) readBlockHeader() error {
+	size, err := z.r.ReadByte()
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if size == 0 { // the index, which is not needed for decoding
+		return io.EOF
+	}
+	header := make([]byte, int(size)*4+4)
+	header[0] = size
+	if _, err := io.ReadFull(z.r, header[1:]); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	n := len(header) - 4
+	if crc32.ChecksumIEEE(header[:n]) != binary.LittleEndian.Uint32(header[n:]) {
+		return errCorrupt
+	}
+	br := bytes.NewReader(header[2:n])
+	flags := header[1]
+	if flags&0x40 != 0 { // the compressed size
+		if _, err := binary.ReadUvarint(br); err != nil {
+			return errCorrupt
+		}
+	}
+	if flags&0x80 != 0 { // the uncompressed size
+		if _, err := binary.ReadUvarint(br); err != nil {
+			return errCorrupt
+		}
+	}
+	// Filters like the x86 BCJ filter are rarely used for archives, so only a single LZMA2 filter is supported
+	if flags&0x03 != 0 {
+		return errors.New("unsupported XZ filter chain")
+	}
+	id, err := binary.ReadUvarint(br)
+	if err != nil || id != 0x21 {
+		return errors.New("unsupported XZ filter")
+	}
+	propsSize, err := binary.ReadUvarint(br)
+	if err != nil || propsSize != 1 {
+		return errCorrupt
+	}
+	dictSizeByte, err := br.ReadByte()
+	if err != nil {
+		return errCorrupt
+	}
+	dictSize, err := lzma2DictSize(dictSizeByte)
+	if err != nil {
+		return err
+	}
+	z.lzma2 = newLZMA2Reader(z.r, dictSize)
+	return nil
+}
+
+// skipBlockPadding skips the padding and the check at the end of a block
+func (z *xzReader) skipBlockPadding() error {
+	padding := (4 - z.lzma2.packed%4) % 4
+	if _, err := z.r.Discard(int(padding) + z.check); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	z.lzma2 = nil
+	return nil
+}
+
+// lzma2DictSize returns the dictionary size for the LZMA2 filter properties
+func lzma2DictSize(b byte) (int, error) {
+	if b > 40 {
+		return 0, errCorrupt
+	}
+	size := uint64(2|b&1) << (b/2 + 11)
+	if b == 40 || size > maxWindowSize {
+		return 0, errors.New("LZMA2 dictionary is too large")
+	}
+	return int(size), nil
+}
+
+// lzma2Reader decodes LZMA2 chunks until the end marker
+type lzma2Reader struct {
+	r       *bufio.Reader
+	packed  int64 // the number of compressed bytes that have been read
+	dict    window
+	lzma    *lzmaDecoder
+	chunk   []byte // the compressed data of the current chunk
+	readPos int    // the position in dict.buf of the first byte that has not been returned by Read
+	done    bool
+}
+
+func newLZMA2Reader(r *bufio.Reader, dictSize int) *lzma2Reader {
+	return &lzma2Reader{r: r, dict: window{size: dictSize}}
+}
+
+func (z *lzma2Reader) Read(p []byte) (int, error) {
+	for z.readPos == len(z.dict.buf) {
+		if z.done {
+			return 0, io.EOF
+		}
+		z.dict.compact(&z.readPos)
+		if err := z.decodeChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, z.dict.buf[z.readPos:])
+	z.readPos += n
+	return n, nil
+}
+
+// readFull reads len(p) compressed bytes
+func (z *lzma2Reader) readFull(p []byte) error {
+	n, err := io.ReadFull(z.r, p)
+	z.packed += int64(n)
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// decodeChunk decodes the next chunk into the dictionary
+func (z *lzma2Reader) decodeChunk() error {
+	var control [1]byte
+	if err := z.readFull(control[:]); err != nil {
+		return err
+	}
+	switch c := control[0]; {
+	case c == 0x00: // the end of the data
+		z.done = true
+		return nil
+	case c == 0x01 || c == 0x02: // uncompressed data, with or without a dictionary reset
+		var size [2]byte
+		if err := z.readFull(size[:]); err != nil {
+			return err
+		}
+		if c == 0x01 {
+			z.dict.reset()
+		}
+		data := make([]byte, int(binary.BigEndian.Uint16(size[:]))+1)
+		if err := z.readFull(data); err != nil {
+			return err
+		}
+		z.dict.write(data)
+		return nil
+	case c >= 0x80: // LZMA compressed data
+		var header [4]byte
+		if err := z.readFull(header[:]); err != nil {
+			return err
+		}
+		unpacked := int(c&0x1f)<<16 + int(binary.BigEndian.Uint16(header[:2])) + 1
+		packed := int(binary.BigEndian.Uint16(header[2:])) + 1
+		reset := c >> 5 & 0x03
+		if reset == 3 {
+			z.dict.reset()
+		}
+		if reset >= 2 {
+			var props [1]byte
+			if err := z.readFull(props[:]); err != nil {
+				return err
+			}
+			lzma, err := newLZMADecoder(props[0])
+			if err != nil {
+				return err
+			}
+			z.lzma = lzma
+		} else if z.lzma == nil {
+			return errCorrupt // the properties must be given in the first LZMA chunk
+		} else if reset == 1 {
+			z.lzma.reset()
+		}
+		if cap(z.chunk) < packed {
+			z.chunk = make([]byte, packed)
+		}
+		z.chunk = z.chunk[:packed]
+		if err := z.readFull(z.chunk); err != nil {
+			return err
+		}
+		return z.lzma.decode(&z.dict, z.chunk, unpacked)
+	}
+	return errCorrupt
+}
+
+// window is the sliding window of uncompressed data, for both LZMA2 and
+// Zstandard. It holds the history that matches can refer to and the data that
+// has not been read yet.
+type window struct {
+	buf   []byte
+	start int   // the position in buf of the first byte after the last reset
+	pos   int64 // the number of bytes since the last reset
+	size  int   // the dictionary or window size
+}
+
+// reset forgets the history, without dropping data that has not been read
+func (d *window) reset() {
+	d.start = len(d.buf)
+	d.pos = 0
+}
+
+// compact drops history that is older than the dictionary size and has been
+// read, once buf has grown to twice the dictionary size
+func (d *window) compact(readPos *int) {
+	drop := min(len(d.buf)-d.size, *readPos)
+	if len(d.buf) < 2*d.size || drop <= 0 {
+		return
+	}
+	d.buf = d.buf[:copy(d.buf, d.buf[drop:])]
+	d.start = max(d.start-drop, 0)
+	*readPos -= drop
+}
+
+func (d *window) write(data []byte) {
+	d.buf = append(d.buf, data...)
+	d.pos += int64(len(data))
+}
+
+// byteAt returns the byte at the given distance back, where 0 is the last byte
+func (d *window) byteAt(dist int) byte {
+	if i := len(d.buf) - dist - 1; i >= d.start {
+		return d.buf[i]
+	}
+	return 0
+}
+
+// copyMatch appends length bytes from the given distance back, where 0 is the last byte
+func (d *window) copyMatch(dist, length int) bool {
+	if dist < 0 || int64(dist) >= d.pos || dist >= d.size {
+		return false
+	}
+	from := len(d.buf) - dist - 1
+	for i := range length {
+		d.buf = append(d.buf, d.buf[from+i])
+	}
+	d.pos += int64(length)
+	return true
+}
+
+// The number of probabilities for each part of the LZMA model
+const (
+	lzmaStates      = 12
+	lzmaPosStates   = 1 << 4 // the maximum number of position states
+	lzmaLenStates   = 4      // the number of length categories for the distance slots
+	lzmaEndPosModel = 14     // distance slots below this use bit tree coded low bits
+	lzmaFullDist    = 1 << (lzmaEndPosModel >> 1)
+	lzmaAlignBits   = 4
+	lzmaMinMatchLen = 2
+)
+
+// lzmaDecoder holds the state of the LZMA decoder between LZMA2 chunks
+type lzmaDecoder struct {
+	lc, lp, pb uint
+
+	state                  int
+	rep                    [4]int
+	isMatch, isRep0Long    [lzmaStates * lzmaPosStates]uint16
+	isRep, isRepG0         [lzmaStates]uint16
+	isRepG1, isRepG2       [lzmaStates]uint16
+	posSlot                [lzmaLenStates][1 << 6]uint16
+	posSpecial             [1 + lzmaFullDist - lzmaEndPosModel]uint16
+	align                  [1 << lzmaAlignBits]uint16
+	literal                []uint16
+	matchLength, repLength lzmaLengthDecoder
+}
+
+// lzmaLengthDecoder decodes the length of a match
+type lzmaLengthDecoder struct {
+	choice, choice2 uint16
+	low, mid        [lzmaPosStates][1 << 3]uint16
+	high            [1 << 8]uint16
+}
+
+// newLZMADecoder returns a decoder for the given lc/lp/pb properties byte
+func newLZMADecoder(props byte) (*lzmaDecoder, error) {
+	if props >= 9*5*5 {
+		return nil, errCorrupt
+	}
+	d := &lzmaDecoder{lc: uint(props % 9), lp: uint(props / 9 % 5), pb: uint(props / 45)}
+	if d.lc+d.lp > 4 { // not allowed in LZMA2
+		return nil, errCorrupt
+	}
+	d.literal = make([]uint16, 0x300<<(d.lc+d.lp))
+	d.reset()
+	return d, nil
+}
+
+// reset resets the state and all probabilities
+func (d *lzmaDecoder) reset() {
+	d.state = 0
+	d.rep = [4]int{}
+	for _, probs := range [][]uint16{d.isMatch[:], d.isRep0Long[:], d.isRep[:], d.isRepG0[:], d.isRepG1[:], d.isRepG2[:], d.posSpecial[:], d.align[:], d.literal} {
+		initProbs(probs)
+	}
+	for i := range d.posSlot {
+		initProbs(d.posSlot[i][:])
+	}
+	for _, l := range []*lzmaLengthDecoder{&d.matchLength, &d.repLength} {
+		l.choice, l.choice2 = 1024, 1024
+		for i := range l.low {
+			initProbs(l.low[i][:])
+			initProbs(l.mid[i][:])
+		}
+		initProbs(l.high[:])
+	}
+}
+
+func initProbs(probs []uint16) {
+	for i := range probs {
+		probs[i] = 1024
+	}
+}
+
+// decode decodes one LZMA2 chunk with exactly unpacked bytes of output into the dictionary
+func (d *lzmaDecoder) decode(dict *window, data []byte, unpacked int) error {
+	rc, err := newRangeDecoder(data)
+	if err != nil {
+		return err
+	}
+	pbMask := int64(1)<<d.pb - 1
+	end := dict.pos + int64(unpacked)
+	for dict.pos < end {
+		posState := int(dict.pos & pbMask)
+		if rc.bit(&d.isMatch[d.state*lzmaPosStates+posState]) == 0 {
+			dict.write([]byte{d.decodeLiteral(rc, dict)})
+			switch {
+			case d.state < 4:
+				d.state = 0
+			case d.state < 10:
+				d.state -= 3
+			default:
+				d.state -= 6
+			}
+			continue
+		}
+		var length int
+		if rc.bit(&d.isRep[d.state]) == 0 { // a match with a new distance
+			length = d.matchLength.decode(rc, posState)
+			d.state = stateAfter(d.state, 7, 10)
+			dist := d.decodeDistance(rc, length)
+			d.rep = [4]int{dist, d.rep[0], d.rep[1], d.rep[2]}
+		} else { // a match with one of the last four distances
+			if rc.bit(&d.isRepG0[d.state]) == 0 {
+				if rc.bit(&d.isRep0Long[d.state*lzmaPosStates+posState]) == 0 { // a single byte
+					d.state = stateAfter(d.state, 9, 11)
+					if !dict.copyMatch(d.rep[0], 1) {
+						return errCorrupt
+					}
+					continue
+				}
+			} else {
+				var dist int
+				if rc.bit(&d.isRepG1[d.state]) == 0 {
+					dist = d.rep[1]
+				} else {
+					if rc.bit(&d.isRepG2[d.state]) == 0 {
+						dist = d.rep[2]
+					} else {
+						dist = d.rep[3]
+						d.rep[3] = d.rep[2]
+					}
+					d.rep[2] = d.rep[1]
+				}
+				d.rep[1] = d.rep[0]
+				d.rep[0] = dist
+			}
+			length = d.repLength.decode(rc, posState)
+			d.state = stateAfter(d.state, 8, 11)
+		}
+		if int64(length) > end-dict.pos || !dict.copyMatch(d.rep[0], length) {
+			return errCorrupt
+		}
+	}
+	if rc.err != nil {
+		return rc.err
+	}
+	return nil
+}
+
+// stateAfter returns the next state after a match, based on if the previous symbol was a literal
+func stateAfter(state, afterLiteral, afterMatch int) int {
+	if state < 7 {
+		return afterLiteral
+	}
+	return afterMatch
+}
+
+func (d *lzmaDecoder) decodeLiteral(rc *rangeDecoder, dict *window) byte {
+	prev := dict.byteAt(0)
+	lpMask := int64(1)<<d.lp - 1
+	probs := d.literal[0x300*(int(dict.pos&lpMask)<<d.lc+int(prev>>(8-d.lc))):]
+	symbol := 1
+	if d.state >= 7 { // the byte at the last match distance is used as context
+		matchByte := int(dict.byteAt(d.rep[0]))
+		for symbol < 0x100 {
+			matchBit := matchByte >> 7 & 1
+			matchByte <<= 1
+			bit := rc.bit(&probs[(1+matchBit)<<8+symbol])
+			symbol = symbol<<1 | bit
+			if bit != matchBit {
+				break
+			}
+		}
+	}
+	for symbol < 0x100 {
+		symbol = symbol<<1 | rc.bit(&probs[symbol])
+	}
+	return byte(symbol)
+}
+
+// decodeDistance decodes the distance of a match, where 0 means the last byte
+func (d *lzmaDecoder) decodeDistance(rc *rangeDecoder, length int) int {
+	lenState := min(length-lzmaMinMatchLen, lzmaLenStates-1)
+	slot := rc.bitTree(d.posSlot[lenState][:], 6)
+	if slot < 4 {
+		return slot
+	}
+	directBits := uint(slot>>1 - 1)
+	dist := (2 | slot&1) << directBits
+	if slot < lzmaEndPosModel {
+		return dist + rc.reverseBitTree(d.posSpecial[dist-slot:], directBits)
+	}
+	dist += rc.directBits(directBits-lzmaAlignBits) << lzmaAlignBits
+	return dist + rc.reverseBitTree(d.align[:], lzmaAlignBits)
+}
+
+func (l *lzmaLengthDecoder) decode(rc *rangeDecoder, posState int) int {
+	if rc.bit(&l.choice) == 0 {
+		return lzmaMinMatchLen + rc.bitTree(l.low[posState][:], 3)
+	}
+	if rc.bit(&l.choice2) == 0 {
+		return lzmaMinMatchLen + 8 + rc.bitTree(l.mid[posState][:], 3)
+	}
+	return lzmaMinMatchLen + 16 + rc.bitTree(l.high[:], 8)
+}
+
+// rangeDecoder is the arithmetic decoder that LZMA is built on. Reading past
+// the end of the data gives zero bytes and sets err.
+type rangeDecoder struct {
+	data      []byte
+	rng, code uint32
+	err       error
+}
+
+func newRangeDecoder(data []byte) (*rangeDecoder, error) {
+	if len(data) < 5 || data[0] != 0 {
+		return nil, errCorrupt
+	}
+	return &rangeDecoder{data: data[5:], rng: 0xffffffff, code: binary.BigEndian.Uint32(data[1:5])}, nil
+}
+
+func (rc *rangeDecoder) normalize() {
+	if rc.rng >= 1<<24 {
+		return
+	}
+	rc.rng <<= 8
+	rc.code <<= 8
+	if len(rc.data) == 0 {
+		rc.err = errCorrupt
+		return
+	}
+	rc.code |= uint32(rc.data[0])
+	rc.data = rc.data[1:]
+}
+
+// bit decodes a single bit with the given probability, and updates the probability
+func (rc *rangeDecoder) bit(prob *uint16) int {
+	bound := (rc.rng >> 11) * uint32(*prob)
+	var bit int
+	if rc.code < bound {
+		rc.rng = bound
+		*prob += (2048 - *prob) >> 5
+	} else {
+		rc.rng -= bound
+		rc.code -= bound
+		*prob -= *prob >> 5
+		bit = 1
+	}
+	rc.normalize()
+	return bit
+}
+
+// bitTree decodes a number with the given number of bits, most significant bit first
+func (rc *rangeDecoder) bitTree(probs []uint16, bits uint) int {
+	m := 1
+	for range bits {
+		m = m<<1 | rc.bit(&probs[m])
+	}
+	return m - 1<<bits
+}
+
+// reverseBitTree decodes a number with the given number of bits, least significant bit first
+func (rc *rangeDecoder) reverseBitTree(probs []uint16, bits uint) int {
+	m, symbol := 1, 0
+	for i := range bits {
+		bit := rc.bit(&probs[m])
+		m = m<<1 | bit
+		symbol |= bit << i
+	}
+	return symbol
+}
+
+// directBits decodes bits that all have a probability of 0.5
+func (rc *rangeDecoder) directBits(bits uint) int {
+	result := 0
+	for range bits {
+		rc.rng >>= 1
+		bit := 0
+		if rc.code >= rc.rng {
+			rc.code -= rc.rng
+			bit = 1
+		}
+		result = result<<1 | bit
+		rc.normalize()
+	}
+	return result
+}
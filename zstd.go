@@ -0,0 +1,704 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// zstdMaxBlockSize is the largest amount of data a Zstandard block can decompress to
+const zstdMaxBlockSize = 128 << 10
+
+// zstdReader decompresses Zstandard frames, as created by "zstd" and
+// "tar --zstd". Dictionaries are not supported and checksums are not verified.
+type zstdReader struct {
+	r        *bufio.Reader
+	win      window
+	readPos  int  // the position in win.buf of the first byte that has not been returned by Read
+	inFrame  bool // true if the next block belongs to the current frame
+	checksum bool // true if the current frame ends with a checksum
+	err      error
+
+	// The state that is kept between the blocks of a frame
+	rep                  [3]int
+	huffman              *huffmanTable
+	litLen, offset, mLen *fseTable
+	literals             []byte
+}
+
+// newZstdReader reads the first frame header and returns a reader for the uncompressed data
+func newZstdReader(r io.Reader) (*zstdReader, error) {
+	z := &zstdReader{r: bufio.NewReader(r)}
+	if err := z.readFrameHeader(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return z, nil
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	for z.readPos == len(z.win.buf) {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.win.compact(&z.readPos)
+		if z.inFrame {
+			z.err = z.decodeBlock()
+		} else {
+			z.err = z.readFrameHeader()
+		}
+	}
+	n := copy(p, z.win.buf[z.readPos:])
+	z.readPos += n
+	return n, nil
+}
+
+// readFrameHeader starts the next frame, skips a skippable frame or returns io.EOF at the end of the data
+func (z *zstdReader) readFrameHeader() error {
+	var magic [4]byte
+	if n, err := io.ReadFull(z.r, magic[:]); n == 0 && err == io.EOF {
+		return io.EOF
+	} else if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if m := binary.LittleEndian.Uint32(magic[:]); m&0xfffffff0 == 0x184d2a50 { // a skippable frame
+		var size [4]byte
+		if _, err := io.ReadFull(z.r, size[:]); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		if _, err := z.r.Discard(int(binary.LittleEndian.Uint32(size[:]))); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	} else if m != 0xfd2fb528 {
+		return errCorrupt
+	}
+	descriptor, err := z.r.ReadByte()
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if descriptor&0x08 != 0 { // a reserved bit
+		return errCorrupt
+	}
+	singleSegment := descriptor&0x20 != 0
+	var windowSize uint64
+	if !singleSegment {
+		b, err := z.r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		base := uint64(1) << (10 + b>>3)
+		windowSize = base + base/8*uint64(b&0x07)
+	}
+	dictIDSize := [4]int{0, 1, 2, 4}[descriptor&0x03]
+	contentSizeSize := [4]int{0, 2, 4, 8}[descriptor>>6]
+	if singleSegment && contentSizeSize == 0 {
+		contentSizeSize = 1
+	}
+	var fields [4 + 8]byte
+	if _, err := io.ReadFull(z.r, fields[:dictIDSize+contentSizeSize]); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if !bytes.Equal(fields[:dictIDSize], make([]byte, dictIDSize)) {
+		return errors.New("Zstandard dictionaries are not supported")
+	}
+	if singleSegment { // the window size is the content size
+		var contentSize [8]byte
+		copy(contentSize[:], fields[dictIDSize:dictIDSize+contentSizeSize])
+		windowSize = binary.LittleEndian.Uint64(contentSize[:])
+		if contentSizeSize == 2 {
+			windowSize += 256
+		}
+	}
+	if windowSize > maxWindowSize {
+		return errors.New("Zstandard window is too large")
+	}
+	z.win.reset()
+	z.win.size = int(windowSize)
+	z.inFrame = true
+	z.checksum = descriptor&0x04 != 0
+	z.rep = [3]int{1, 4, 8}
+	z.huffman = nil
+	z.litLen, z.offset, z.mLen = nil, nil, nil
+	return nil
+}
+
+// decodeBlock decodes the next block of the current frame into the window
+func (z *zstdReader) decodeBlock() error {
+	var header [3]byte
+	if _, err := io.ReadFull(z.r, header[:]); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	h := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	last, blockType, size := h&1 == 1, h>>1&0x03, h>>3
+	if size > zstdMaxBlockSize {
+		return errCorrupt
+	}
+	switch blockType {
+	case 0: // raw data
+		data := make([]byte, size)
+		if _, err := io.ReadFull(z.r, data); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		z.win.write(data)
+	case 1: // a single byte, repeated
+		b, err := z.r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		z.win.write(bytes.Repeat([]byte{b}, size))
+	case 2: // compressed data
+		data := make([]byte, size)
+		if _, err := io.ReadFull(z.r, data); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		if err := z.decodeCompressedBlock(data); err != nil {
+			return err
+		}
+	default:
+		return errCorrupt
+	}
+	if last {
+		z.inFrame = false
+		if z.checksum {
+			if _, err := z.r.Discard(4); err != nil {
+				return io.ErrUnexpectedEOF
+			}
+		}
+	}
+	return nil
+}
+
+// decodeCompressedBlock decodes the literals and sequences of a compressed block
+func (z *zstdReader) decodeCompressedBlock(data []byte) error {
+	literals, data, err := z.decodeLiterals(data)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return errCorrupt
+	}
+	var count int
+	switch b := int(data[0]); {
+	case b < 128:
+		count, data = b, data[1:]
+	case b < 255 && len(data) >= 2:
+		count, data = (b-128)<<8+int(data[1]), data[2:]
+	case b == 255 && len(data) >= 3:
+		count, data = int(data[1])+int(data[2])<<8+0x7f00, data[3:]
+	default:
+		return errCorrupt
+	}
+	if count == 0 {
+		z.win.write(literals)
+		return nil
+	}
+	if len(data) == 0 || data[0]&0x03 != 0 {
+		return errCorrupt
+	}
+	modes := data[0]
+	data = data[1:]
+	if z.litLen, err = readSequenceTable(modes>>6, &data, z.litLen, zstdLitLenTable, 9, len(zstdLitLenCodes)-1); err != nil {
+		return err
+	}
+	if z.offset, err = readSequenceTable(modes>>4&0x03, &data, z.offset, zstdOffsetTable, 8, 31); err != nil {
+		return err
+	}
+	if z.mLen, err = readSequenceTable(modes>>2&0x03, &data, z.mLen, zstdMatchLenTable, 9, len(zstdMatchLenCodes)-1); err != nil {
+		return err
+	}
+	br, err := newBackwardBits(data)
+	if err != nil {
+		return err
+	}
+	litLen, offset, mLen := z.litLen.start(br), z.offset.start(br), z.mLen.start(br)
+	blockEnd := z.win.pos + zstdMaxBlockSize
+	for i := range count {
+		offsetCode := z.offset.entries[offset].symbol
+		offsetValue := uint64(1)<<offsetCode + br.read(int(offsetCode))
+		mLenCode := zstdMatchLenCodes[z.mLen.entries[mLen].symbol]
+		matchLength := int(mLenCode.base) + int(br.read(int(mLenCode.bits)))
+		litLenCode := zstdLitLenCodes[z.litLen.entries[litLen].symbol]
+		literalLength := int(litLenCode.base) + int(br.read(int(litLenCode.bits)))
+		if i < count-1 {
+			litLen = z.litLen.next(litLen, br)
+			mLen = z.mLen.next(mLen, br)
+			offset = z.offset.next(offset, br)
+		}
+		if br.pos < 0 || literalLength > len(literals) || z.win.pos+int64(literalLength+matchLength) > blockEnd || offsetValue > maxWindowSize+3 {
+			return errCorrupt
+		}
+		z.win.write(literals[:literalLength])
+		literals = literals[literalLength:]
+		if !z.win.copyMatch(z.matchOffset(int(offsetValue), literalLength)-1, matchLength) {
+			return errCorrupt
+		}
+	}
+	if br.pos != 0 {
+		return errCorrupt
+	}
+	z.win.write(literals)
+	return nil
+}
+
+// matchOffset returns the offset for the given offset value, and updates the repeated offsets
+func (z *zstdReader) matchOffset(offsetValue, literalLength int) int {
+	if offsetValue > 3 {
+		z.rep = [3]int{offsetValue - 3, z.rep[0], z.rep[1]}
+		return offsetValue - 3
+	}
+	i := offsetValue - 1
+	if literalLength == 0 {
+		i++
+	}
+	if i == 0 {
+		return z.rep[0]
+	}
+	var offset int
+	if i == 3 {
+		offset = z.rep[0] - 1
+	} else {
+		offset = z.rep[i]
+	}
+	if i != 1 {
+		z.rep[2] = z.rep[1]
+	}
+	z.rep[1] = z.rep[0]
+	z.rep[0] = offset
+	return offset
+}
+
+// decodeLiterals decodes the literals section at the start of a compressed
+// block, and returns the literals and the rest of the block
+func (z *zstdReader) decodeLiterals(data []byte) ([]byte, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errCorrupt
+	}
+	literalsType, sizeFormat := data[0]&0x03, data[0]>>2&0x03
+	if literalsType < 2 { // raw or a single repeated byte
+		headerSize := [4]int{1, 2, 1, 3}[sizeFormat]
+		if len(data) < headerSize+1 {
+			return nil, nil, errCorrupt
+		}
+		var size int
+		switch headerSize {
+		case 1:
+			size = int(data[0] >> 3)
+		case 2:
+			size = int(data[0]>>4) + int(data[1])<<4
+		case 3:
+			size = int(data[0]>>4) + int(data[1])<<4 + int(data[2])<<12
+		}
+		if size > zstdMaxBlockSize {
+			return nil, nil, errCorrupt
+		}
+		if literalsType == 1 {
+			return bytes.Repeat(data[headerSize:headerSize+1], size), data[headerSize+1:], nil
+		}
+		if len(data) < headerSize+size {
+			return nil, nil, errCorrupt
+		}
+		return data[headerSize : headerSize+size], data[headerSize+size:], nil
+	}
+
+	// Huffman coded literals, with a new Huffman table or the one from the previous block
+	headerSize, sizeBits, streams := [4]int{3, 3, 4, 5}[sizeFormat], [4]uint{10, 10, 14, 18}[sizeFormat], 4
+	if sizeFormat == 0 {
+		streams = 1
+	}
+	if len(data) < headerSize {
+		return nil, nil, errCorrupt
+	}
+	var header [8]byte
+	copy(header[:], data[:headerSize])
+	sizes := binary.LittleEndian.Uint64(header[:]) >> 4
+	size, compressedSize := int(sizes&(1<<sizeBits-1)), int(sizes>>sizeBits&(1<<sizeBits-1))
+	if size > zstdMaxBlockSize || len(data) < headerSize+compressedSize {
+		return nil, nil, errCorrupt
+	}
+	rest := data[headerSize+compressedSize:]
+	data = data[headerSize : headerSize+compressedSize]
+	if literalsType == 2 {
+		table, n, err := readHuffmanTable(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		z.huffman = table
+		data = data[n:]
+	} else if z.huffman == nil {
+		return nil, nil, errCorrupt
+	}
+	if cap(z.literals) < size {
+		z.literals = make([]byte, size)
+	}
+	literals := z.literals[:size]
+	if streams == 1 {
+		return literals, rest, z.huffman.decode(data, literals)
+	}
+	if len(data) < 6 {
+		return nil, nil, errCorrupt
+	}
+	segment := (size + 3) / 4
+	if size < 3*segment {
+		return nil, nil, errCorrupt
+	}
+	jumps := data[:6]
+	data = data[6:]
+	for i := range streams {
+		n := len(data)
+		if i < 3 {
+			n = int(binary.LittleEndian.Uint16(jumps[2*i:]))
+		}
+		out := literals[min(i*segment, size):min((i+1)*segment, size)]
+		if n > len(data) {
+			return nil, nil, errCorrupt
+		}
+		if err := z.huffman.decode(data[:n], out); err != nil {
+			return nil, nil, err
+		}
+		data = data[n:]
+	}
+	return literals, rest, nil
+}
+
+// backwardBits reads a bitstream from the end to the start, as used for FSE and Huffman coded data
+type backwardBits struct {
+	data []byte
+	pos  int // the number of bits that are left, which is negative if more bits than available were read
+}
+
+func newBackwardBits(data []byte) (*backwardBits, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 { // the last byte must contain the padding marker
+		return nil, errCorrupt
+	}
+	return &backwardBits{data: data, pos: (len(data)-1)*8 + bits.Len8(data[len(data)-1]) - 1}, nil
+}
+
+// peek returns the next n bits, which are zero past the start of the bitstream
+func (b *backwardBits) peek(n int) uint64 {
+	if b.pos >= n {
+		return bitsAt(b.data, b.pos-n, n)
+	}
+	if b.pos <= 0 {
+		return 0
+	}
+	return bitsAt(b.data, 0, b.pos) << (n - b.pos)
+}
+
+func (b *backwardBits) read(n int) uint64 {
+	v := b.peek(n)
+	b.pos -= n
+	return v
+}
+
+// bitsAt returns n (at most 56) bits, starting at the given bit position of
+// the little-endian number in data
+func bitsAt(data []byte, start, n int) uint64 {
+	var buf [8]byte
+	if i := start / 8; i < len(data) {
+		copy(buf[:], data[i:])
+	}
+	return binary.LittleEndian.Uint64(buf[:]) >> (start % 8) & (1<<n - 1)
+}
+
+// fseEntry is a state in an FSE decoding table
+type fseEntry struct {
+	symbol uint8
+	bits   uint8  // the number of bits to read for the next state
+	base   uint16 // the next state, before the bits are added
+}
+
+// fseTable is a decoding table for finite state entropy coded data
+type fseTable struct {
+	log     int
+	entries []fseEntry
+}
+
+// start reads the initial state
+func (t *fseTable) start(br *backwardBits) int {
+	return int(br.read(t.log))
+}
+
+// next reads the state that follows the given state
+func (t *fseTable) next(state int, br *backwardBits) int {
+	e := t.entries[state]
+	return int(e.base) + int(br.read(int(e.bits)))
+}
+
+// readFSETable reads a table description and returns the table and the number of bytes read
+func readFSETable(data []byte, maxLog, maxSymbol int) (*fseTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errCorrupt
+	}
+	pos := 0
+	read := func(n int) int {
+		v := int(bitsAt(data, pos, n))
+		pos += n
+		return v
+	}
+	log := read(4) + 5
+	if log > maxLog {
+		return nil, 0, errCorrupt
+	}
+	var (
+		counts    []int16
+		remaining = 1<<log + 1
+		threshold = 1 << log
+		nbBits    = log + 1
+	)
+	for remaining > 1 && len(counts) <= maxSymbol {
+		limit := 2*threshold - 1 - remaining
+		count := int(bitsAt(data, pos, nbBits-1))
+		if count < limit {
+			pos += nbBits - 1
+		} else {
+			count = read(nbBits)
+			if count >= threshold {
+				count -= limit
+			}
+		}
+		count-- // -1 is a probability of "less than one"
+		remaining -= max(count, -count)
+		counts = append(counts, int16(count))
+		for count == 0 { // the number of zero counts that follow, in groups of up to three
+			repeat := read(2)
+			for range repeat {
+				counts = append(counts, 0)
+			}
+			if repeat < 3 {
+				break
+			}
+		}
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 || len(counts) > maxSymbol+1 || pos > len(data)*8 {
+		return nil, 0, errCorrupt
+	}
+	return buildFSETable(counts, log), (pos + 7) / 8, nil
+}
+
+// buildFSETable returns the decoding table for the given normalized counts, which add up to 1<<log
+func buildFSETable(counts []int16, log int) *fseTable {
+	size := 1 << log
+	t := &fseTable{log: log, entries: make([]fseEntry, size)}
+	next := make([]int, len(counts))
+	high := size - 1
+	for s, count := range counts { // symbols with a probability of "less than one" go at the end
+		if count == -1 {
+			t.entries[high].symbol = uint8(s)
+			high--
+			next[s] = 1
+		} else {
+			next[s] = int(count)
+		}
+	}
+	pos, step := 0, size>>1+size>>3+3
+	for s, count := range counts {
+		for range count {
+			t.entries[pos].symbol = uint8(s)
+			pos = (pos + step) & (size - 1)
+			for pos > high {
+				pos = (pos + step) & (size - 1)
+			}
+		}
+	}
+	for i := range t.entries {
+		e := &t.entries[i]
+		n := next[e.symbol]
+		next[e.symbol]++
+		e.bits = uint8(log - (bits.Len(uint(n)) - 1))
+		e.base = uint16(n<<e.bits - size)
+	}
+	return t
+}
+
+// readSequenceTable returns the FSE table for literal lengths, offsets or
+// match lengths, based on the mode from the block. data is advanced past the
+// table description.
+func readSequenceTable(mode byte, data *[]byte, previous, predefined *fseTable, maxLog, maxSymbol int) (*fseTable, error) {
+	switch mode {
+	case 0: // the predefined table
+		return predefined, nil
+	case 1: // a single symbol
+		if len(*data) == 0 || int((*data)[0]) > maxSymbol {
+			return nil, errCorrupt
+		}
+		t := &fseTable{entries: []fseEntry{{symbol: (*data)[0]}}}
+		*data = (*data)[1:]
+		return t, nil
+	case 2: // a table description
+		t, n, err := readFSETable(*data, maxLog, maxSymbol)
+		if err != nil {
+			return nil, err
+		}
+		*data = (*data)[n:]
+		return t, nil
+	}
+	// the table from the previous block
+	if previous == nil {
+		return nil, errCorrupt
+	}
+	return previous, nil
+}
+
+// zstdCode is the base value and number of extra bits for a literal length or match length code
+type zstdCode struct {
+	base uint32
+	bits uint8
+}
+
+var (
+	zstdLitLenCodes = []zstdCode{
+		{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0},
+		{8, 0}, {9, 0}, {10, 0}, {11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0},
+		{16, 1}, {18, 1}, {20, 1}, {22, 1}, {24, 2}, {28, 2}, {32, 3}, {40, 3},
+		{48, 4}, {64, 6}, {128, 7}, {256, 8}, {512, 9}, {1024, 10}, {2048, 11}, {4096, 12},
+		{8192, 13}, {16384, 14}, {32768, 15}, {65536, 16},
+	}
+	zstdMatchLenCodes = []zstdCode{
+		{3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0}, {8, 0}, {9, 0}, {10, 0},
+		{11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0}, {16, 0}, {17, 0}, {18, 0},
+		{19, 0}, {20, 0}, {21, 0}, {22, 0}, {23, 0}, {24, 0}, {25, 0}, {26, 0},
+		{27, 0}, {28, 0}, {29, 0}, {30, 0}, {31, 0}, {32, 0}, {33, 0}, {34, 0},
+		{35, 1}, {37, 1}, {39, 1}, {41, 1}, {43, 2}, {47, 2}, {51, 3}, {59, 3},
+		{67, 4}, {83, 4}, {99, 5}, {131, 7}, {259, 8}, {515, 9}, {1027, 10}, {2051, 11},
+		{4099, 12}, {8195, 13}, {16387, 14}, {32771, 15}, {65539, 16},
+	}
+
+	// The predefined FSE tables
+	zstdLitLenTable = buildFSETable([]int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}, 6)
+	zstdMatchLenTable = buildFSETable([]int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}, 6)
+	zstdOffsetTable = buildFSETable([]int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}, 5)
+)
+
+// huffmanEntry is an entry in a Huffman decoding table
+type huffmanEntry struct {
+	symbol byte
+	bits   uint8
+}
+
+// huffmanTable is indexed by the next maxBits bits of a Huffman coded stream
+type huffmanTable struct {
+	maxBits int
+	entries []huffmanEntry
+}
+
+// readHuffmanTable reads a Huffman tree description and returns the table and the number of bytes read
+func readHuffmanTable(data []byte) (*huffmanTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errCorrupt
+	}
+	var (
+		weights []byte
+		n       int
+	)
+	if header := int(data[0]); header >= 128 { // 4-bit weights
+		count := header - 127
+		n = 1 + (count+1)/2
+		if len(data) < n {
+			return nil, 0, errCorrupt
+		}
+		for i := range count {
+			weights = append(weights, data[1+i/2]>>(4*(1-i%2))&0x0f)
+		}
+	} else { // FSE coded weights, with two interleaved states
+		n = 1 + header
+		if len(data) < n {
+			return nil, 0, errCorrupt
+		}
+		t, tableSize, err := readFSETable(data[1:n], 6, 255)
+		if err != nil {
+			return nil, 0, err
+		}
+		br, err := newBackwardBits(data[1+tableSize : n])
+		if err != nil {
+			return nil, 0, err
+		}
+		states := [2]int{t.start(br), t.start(br)}
+		for i := 0; ; i = 1 - i {
+			if len(weights) >= 255 {
+				return nil, 0, errCorrupt
+			}
+			weights = append(weights, t.entries[states[i]].symbol)
+			states[i] = t.next(states[i], br)
+			if br.pos < 0 { // the last weight comes from the other state
+				weights = append(weights, t.entries[states[1-i]].symbol)
+				break
+			}
+		}
+	}
+
+	// The weight of the last symbol is implied by the total
+	total := 0
+	for _, w := range weights {
+		if w > 11 {
+			return nil, 0, errCorrupt
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 || len(weights) > 255 {
+		return nil, 0, errCorrupt
+	}
+	maxBits := bits.Len(uint(total))
+	rest := 1<<maxBits - total
+	if maxBits > 11 || rest&(rest-1) != 0 {
+		return nil, 0, errCorrupt
+	}
+	weights = append(weights, byte(bits.Len(uint(rest))))
+
+	// Symbols with lower weights get the lower codes
+	t := &huffmanTable{maxBits: maxBits, entries: make([]huffmanEntry, 1<<maxBits)}
+	pos := 0
+	for w := 1; w <= maxBits; w++ {
+		for s, weight := range weights {
+			if int(weight) != w {
+				continue
+			}
+			entry := huffmanEntry{symbol: byte(s), bits: uint8(maxBits + 1 - w)}
+			for range 1 << (w - 1) {
+				t.entries[pos] = entry
+				pos++
+			}
+		}
+	}
+	return t, n, nil
+}
+
+// decode decodes len(out) symbols from a Huffman coded stream, which must be used up exactly
+func (t *huffmanTable) decode(data []byte, out []byte) error {
+	br, err := newBackwardBits(data)
+	if err != nil {
+		return err
+	}
+	for i := range out {
+		e := t.entries[br.peek(t.maxBits)]
+		out[i] = e.symbol
+		br.pos -= int(e.bits)
+	}
+	if br.pos != 0 {
+		return errCorrupt
+	}
+	return nil
+}